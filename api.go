@@ -0,0 +1,221 @@
+package vimego
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// viewer is the session of an anonymous visitor of the site.
+type viewer struct {
+	JWT   string `json:"jwt"`
+	XSRFT string `json:"xsrft"`
+	VUID  string `json:"vuid"`
+}
+
+// apiSession keeps the viewer of a listing between the requests.
+type apiSession struct {
+	mu     sync.Mutex
+	viewer *viewer
+}
+
+// apiClient sends the requests of a listing to the API.
+type apiClient struct {
+	header     map[string][]string
+	httpClient *http.Client
+	endpoints  *Endpoints
+	cache      Cache
+	session    *apiSession
+}
+
+// VideoPage is a page of the videos of a listing.
+type VideoPage struct {
+	Total   int
+	Page    int
+	PerPage int
+	Videos  []*Video
+	// Last is true if there are no more pages.
+	Last bool
+}
+
+// getViewer returns the viewer session, a new one if renew is true.
+func (c *apiClient) getViewer(ctx context.Context, renew bool) (*viewer, error) {
+	c.session.mu.Lock()
+	defer c.session.mu.Unlock()
+	if c.session.viewer != nil && !renew {
+		return c.session.viewer, nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", c.endpoints.withDefaults().Site+"/_rv/viewer", nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header = copyHeader(c.header)
+	req.Header.Set("X-Requested-With", "XMLHttpRequest")
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 400 {
+		return nil, ErrUnexpectedStatusCode(resp.StatusCode)
+	}
+
+	var result viewer
+	err = json.NewDecoder(resp.Body).Decode(&result)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't decode viewer JSON: %w", err)
+	}
+	c.session.viewer = &result
+	return c.session.viewer, nil
+}
+
+// get decodes the response of the API to the path into result.
+// The token is renewed once if it's rejected.
+func (c *apiClient) get(ctx context.Context, path string, result interface{}) error {
+	for renew := false; ; renew = true {
+		v, err := c.getViewer(ctx, renew)
+		if err != nil {
+			return err
+		}
+
+		req, err := http.NewRequestWithContext(ctx, "GET", c.endpoints.withDefaults().API+path, nil)
+		if err != nil {
+			return err
+		}
+		req.Header = copyHeader(c.header)
+		req.Header.Set("Authorization", "jwt "+v.JWT)
+		req.Header.Set("Accept", "application/json")
+		resp, err := c.httpClient.Do(req)
+		if err != nil {
+			return err
+		}
+		if resp.StatusCode == http.StatusUnauthorized && !renew {
+			resp.Body.Close()
+			continue
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode != http.StatusOK {
+			return ErrUnexpectedStatusCode(resp.StatusCode)
+		}
+		err = json.NewDecoder(resp.Body).Decode(result)
+		if err != nil {
+			return fmt.Errorf("couldn't decode API JSON: %w", err)
+		}
+		return nil
+	}
+}
+
+// videoPage returns a page of the videos listed at path.
+func (c *apiClient) videoPage(ctx context.Context, path string, page, perPage int, params url.Values) (*VideoPage, error) {
+	if perPage <= 0 {
+		perPage = 100
+	}
+	if params == nil {
+		params = url.Values{}
+	}
+	params.Set("fields", "link,uri")
+	params.Set("page", fmt.Sprint(page))
+	params.Set("per_page", fmt.Sprint(perPage))
+
+	var result struct {
+		Total   int `json:"total"`
+		Page    int `json:"page"`
+		PerPage int `json:"per_page"`
+		Paging  struct {
+			Next string `json:"next"`
+		} `json:"paging"`
+		Data []struct {
+			URI  string `json:"uri"`
+			Link string `json:"link"`
+		} `json:"data"`
+	}
+	err := c.get(ctx, path+"?"+params.Encode(), &result)
+	if statusErr, ok := err.(ErrUnexpectedStatusCode); ok && statusErr == http.StatusBadRequest && page > 1 {
+		// the page is after the last one
+		return &VideoPage{Page: page, PerPage: perPage, Last: true}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	videos := make([]*Video, 0, len(result.Data))
+	for _, item := range result.Data {
+		// the links may be vanity URLs without the ID, the URIs are
+		// /videos/<id> or /videos/<id>:<hash>; the others are skipped
+		videoId, hash, ok := parseVideoURI(item.URI)
+		if !ok {
+			continue
+		}
+		videos = append(videos, c.newVideo(videoId, hash))
+	}
+	return &VideoPage{
+		Total:   result.Total,
+		Page:    result.Page,
+		PerPage: result.PerPage,
+		Videos:  videos,
+		Last:    result.Paging.Next == "" || len(result.Data) == 0,
+	}, nil
+}
+
+// newVideo returns a Video sharing the settings of the listing.
+func (c *apiClient) newVideo(videoId int, hash string) *Video {
+	videoUrl := fmt.Sprintf("%s/%v", c.endpoints.withDefaults().Site, videoId)
+	if hash != "" {
+		videoUrl += "/" + hash
+	}
+	return &Video{
+		Url:        videoUrl,
+		VideoId:    videoId,
+		Hash:       hash,
+		HTTPClient: c.httpClient,
+		Header:     copyHeader(c.header),
+		Endpoints:  c.endpoints,
+		Cache:      c.cache,
+	}
+}
+
+// parseVideoURI returns the ID and the privacy hash of an API URI
+// like /videos/206152466:3c5a1f9e0b.
+func parseVideoURI(uri string) (int, string, bool) {
+	if !strings.HasPrefix(uri, "/videos/") {
+		return 0, "", false
+	}
+	id := strings.TrimPrefix(uri, "/videos/")
+	var hash string
+	if i := strings.IndexByte(id, ':'); i != -1 {
+		id, hash = id[:i], id[i+1:]
+		if !hashPattern.MatchString(hash) {
+			return 0, "", false
+		}
+	}
+	if !idPattern.MatchString(id) {
+		return 0, "", false
+	}
+	videoId, err := strconv.Atoi(id)
+	if err != nil {
+		return 0, "", false
+	}
+	return videoId, hash, true
+}
+
+// allVideos returns the videos of all the pages.
+func allVideos(ctx context.Context, pageFunc func(ctx context.Context, page int) (*VideoPage, error)) ([]*Video, error) {
+	var videos []*Video
+	for page := 1; ; page++ {
+		result, err := pageFunc(ctx, page)
+		if err != nil {
+			return nil, err
+		}
+		videos = append(videos, result.Videos...)
+		if result.Last {
+			return videos, nil
+		}
+	}
+}
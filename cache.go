@@ -0,0 +1,154 @@
+package vimego
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Cache stores the responses for the metadata, the player config and the DASH JSON.
+type Cache interface {
+	// Get returns the value if it's found and not expired.
+	Get(key string) ([]byte, bool)
+	// Set stores the value. expires is when the signed URLs in the value
+	// expire, it's zero if there are none.
+	Set(key string, value []byte, expires time.Time)
+}
+
+// cacheExpiry limits expires by the TTL, 0 means no limit.
+func cacheExpiry(expires time.Time, ttl time.Duration) time.Time {
+	if ttl <= 0 {
+		return expires
+	}
+	limit := time.Now().Add(ttl)
+	if expires.IsZero() || limit.Before(expires) {
+		return limit
+	}
+	return expires
+}
+
+func isExpired(expires time.Time) bool {
+	return !expires.IsZero() && !time.Now().Before(expires)
+}
+
+type cacheEntry struct {
+	value   []byte
+	expires time.Time
+}
+
+// MemoryCache is a Cache keeping the values in memory.
+type MemoryCache struct {
+	// TTL limits how long the values are kept, 0 means no limit.
+	TTL time.Duration
+
+	mu      sync.Mutex
+	entries map[string]cacheEntry
+}
+
+// NewMemoryCache creates a new MemoryCache.
+func NewMemoryCache(ttl time.Duration) *MemoryCache {
+	return &MemoryCache{TTL: ttl, entries: map[string]cacheEntry{}}
+}
+
+func (c *MemoryCache) Get(key string) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	if isExpired(entry.expires) {
+		delete(c.entries, key)
+		return nil, false
+	}
+	return entry.value, true
+}
+
+func (c *MemoryCache) Set(key string, value []byte, expires time.Time) {
+	expires = cacheExpiry(expires, c.TTL)
+	if isExpired(expires) {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.entries == nil {
+		c.entries = map[string]cacheEntry{}
+	}
+	c.entries[key] = cacheEntry{value: value, expires: expires}
+}
+
+// DiskCache is a Cache keeping the values in files.
+// Errors are ignored, the values are just not cached.
+type DiskCache struct {
+	Dir string
+	// TTL limits how long the values are kept, 0 means no limit.
+	TTL time.Duration
+}
+
+// NewDiskCache creates a new DiskCache storing the files in dir.
+func NewDiskCache(dir string, ttl time.Duration) *DiskCache {
+	return &DiskCache{Dir: dir, TTL: ttl}
+}
+
+func (c *DiskCache) path(key string) string {
+	hash := sha256.Sum256([]byte(key))
+	return filepath.Join(c.Dir, hex.EncodeToString(hash[:]))
+}
+
+func (c *DiskCache) Get(key string) ([]byte, bool) {
+	// the files start with the expiry in Unix nanoseconds, 0 if none
+	path := c.path(key)
+	data, err := os.ReadFile(path)
+	if err != nil || len(data) < 8 {
+		return nil, false
+	}
+
+	var expires time.Time
+	if nanos := int64(binary.BigEndian.Uint64(data)); nanos != 0 {
+		expires = time.Unix(0, nanos)
+	}
+	if isExpired(expires) {
+		os.Remove(path)
+		return nil, false
+	}
+	return data[8:], true
+}
+
+func (c *DiskCache) Set(key string, value []byte, expires time.Time) {
+	expires = cacheExpiry(expires, c.TTL)
+	if isExpired(expires) {
+		return
+	}
+	if err := os.MkdirAll(c.Dir, 0o755); err != nil {
+		return
+	}
+
+	data := make([]byte, 8, 8+len(value))
+	if !expires.IsZero() {
+		binary.BigEndian.PutUint64(data, uint64(expires.UnixNano()))
+	}
+	data = append(data, value...)
+
+	// the file is renamed so readers never see a partial one
+	file, err := os.CreateTemp(c.Dir, "tmp-")
+	if err != nil {
+		return
+	}
+	_, err = file.Write(data)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(file.Name(), c.path(key))
+	}
+	if err != nil {
+		os.Remove(file.Name())
+	}
+}
@@ -0,0 +1,96 @@
+package vimego
+
+import (
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestVideoCache(t *testing.T) {
+	fake := newFakeVimeo(t)
+	cache := NewMemoryCache(time.Hour)
+
+	var dashUrl string
+	for i := 0; i < 2; i++ {
+		video := fake.video()
+		video.Cache = cache
+
+		if _, err := video.Metadata(); err != nil {
+			t.Fatal(err)
+		}
+		formats, err := video.Formats()
+		if err != nil {
+			t.Fatal(err)
+		}
+		dashUrl = formats.Dash.Url()
+		if _, err := video.GetDashStreams(dashUrl); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	dashPath, _ := url.Parse(dashUrl)
+	for _, path := range []string{
+		"/api/v2/video/206152466.json",
+		"/video/206152466/config",
+		dashPath.Path,
+	} {
+		if n := fake.count(path); n != 1 {
+			t.Errorf("%s was requested %d times", path, n)
+		}
+	}
+}
+
+func TestSignedExpiry(t *testing.T) {
+	data := []byte(`{"akamai": "https://vod-adaptive.akamaized.net/exp=1640995200~acl=%2F1%2F%2A~hmac=0f1e/1/master.json",
+		"fastly": "https://skyfire.vimeocdn.com/1640991600-0x3c2a/1/master.json"}`)
+	expected := time.Unix(1640991600, 0).Add(-signedUrlMargin)
+	if expires := signedExpiry(data); !expires.Equal(expected) {
+		t.Errorf("signedExpiry() == %v", expires)
+	}
+	if expires := signedExpiry([]byte("metadata:1")); !expires.IsZero() {
+		t.Errorf("signedExpiry() == %v", expires)
+	}
+}
+
+func testCache(t *testing.T, cache Cache) {
+	cache.Set("key", []byte("value"), time.Time{})
+	if value, ok := cache.Get("key"); !ok || string(value) != "value" {
+		t.Errorf("Get() == %q, %v", value, ok)
+	}
+	if _, ok := cache.Get("missing"); ok {
+		t.Error("a missing key was found")
+	}
+
+	// the values with expired URLs aren't stored
+	cache.Set("expired", []byte("value"), time.Now().Add(-time.Second))
+	if _, ok := cache.Get("expired"); ok {
+		t.Error("an expired value was found")
+	}
+
+	cache.Set("short", []byte("value"), time.Now().Add(20*time.Millisecond))
+	time.Sleep(30 * time.Millisecond)
+	if _, ok := cache.Get("short"); ok {
+		t.Error("an expired value was found")
+	}
+}
+
+func TestMemoryCache(t *testing.T) {
+	testCache(t, NewMemoryCache(0))
+
+	cache := NewMemoryCache(10 * time.Millisecond)
+	cache.Set("key", []byte("value"), time.Now().Add(time.Hour))
+	time.Sleep(20 * time.Millisecond)
+	if _, ok := cache.Get("key"); ok {
+		t.Error("the TTL is ignored")
+	}
+}
+
+func TestDiskCache(t *testing.T) {
+	dir := t.TempDir()
+	testCache(t, NewDiskCache(dir, 0))
+
+	// the values are kept in the files
+	if value, ok := NewDiskCache(dir, 0).Get("key"); !ok || string(value) != "value" {
+		t.Errorf("Get() == %q, %v", value, ok)
+	}
+}
@@ -0,0 +1,284 @@
+package vimego
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Chapter is a chapter of the video, End is the start of the next one.
+type Chapter struct {
+	Title string
+	Start time.Duration
+	End   time.Duration
+}
+
+type Chapters []*Chapter
+
+// Chapters returns the chapters of the video, it's empty if there are none.
+func (v *Video) Chapters() (Chapters, error) {
+	return v.ChaptersContext(context.Background())
+}
+
+// ChaptersContext is like Chapters but uses ctx for the requests.
+func (v *Video) ChaptersContext(ctx context.Context) (Chapters, error) {
+	config, err := v.playerConfig(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var configData struct {
+		Embed struct {
+			Chapters []struct {
+				Title string `json:"title"`
+				// Timecode is the start in seconds.
+				Timecode float64 `json:"timecode"`
+			} `json:"chapters"`
+		} `json:"embed"`
+		Video struct {
+			Duration int `json:"duration"`
+		} `json:"video"`
+	}
+	err = json.Unmarshal(config, &configData)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't decode config JSON: %w", err)
+	}
+
+	var chapters Chapters
+	for _, chapter := range configData.Embed.Chapters {
+		chapters = append(chapters, &Chapter{
+			Title: chapter.Title,
+			Start: time.Duration(math.Round(chapter.Timecode * float64(time.Second))),
+		})
+	}
+	for i, chapter := range chapters {
+		if i+1 < len(chapters) {
+			chapter.End = chapters[i+1].Start
+		} else {
+			chapter.End = time.Duration(configData.Video.Duration) * time.Second
+		}
+	}
+	return chapters, nil
+}
+
+// Cues returns the chapters as cues, the chapter titles are escaped.
+func (c Chapters) Cues() Cues {
+	var cues Cues
+	for i, chapter := range c {
+		cues = append(cues, &Cue{
+			ID:    fmt.Sprintf("chapter-%d", i+1),
+			Start: chapter.Start,
+			End:   chapter.end(),
+			Text:  vttEscaper.Replace(chapter.Title),
+		})
+	}
+	return cues
+}
+
+// VTT returns the chapters as a WebVTT file of kind chapters.
+func (c Chapters) VTT() []byte {
+	return c.Cues().VTT()
+}
+
+var ffmetadataEscaper = strings.NewReplacer(
+	`\`, `\\`, "=", `\=`, ";", `\;`, "#", `\#`, "\n", "\\\n",
+)
+
+// FFMetadata returns the chapters in the FFmpeg metadata format,
+// it can be added to a file with ffmpeg -i metadata.txt -map_chapters 1.
+func (c Chapters) FFMetadata() []byte {
+	var buf bytes.Buffer
+	buf.WriteString(";FFMETADATA1\n")
+	for _, chapter := range c {
+		fmt.Fprintf(&buf, "\n[CHAPTER]\nTIMEBASE=1/1000\nSTART=%d\nEND=%d\ntitle=%s\n",
+			chapter.Start.Milliseconds(), chapter.end().Milliseconds(),
+			ffmetadataEscaper.Replace(chapter.Title))
+	}
+	return buf.Bytes()
+}
+
+// end returns the end of the chapter, or its start if it's unknown.
+func (c *Chapter) end() time.Duration {
+	if c.End < c.Start {
+		return c.Start
+	}
+	return c.End
+}
+
+// chapterTimescale is the timescale of the chapter track, in milliseconds.
+const chapterTimescale = 1000
+
+// chapterSamples returns the samples of the chapter track, QuickTime text
+// samples with the UTF-8 encoding. The sizes of the samples are returned too.
+func chapterSamples(chapters Chapters) ([]byte, []uint32) {
+	var data []byte
+	var sizes []uint32
+	for _, chapter := range chapters {
+		title := truncateUTF8(chapter.Title, 0xFFFF)
+		start := len(data)
+		data = appendUint16(data, uint16(len(title)))
+		data = append(data, title...)
+		// encd, the text is UTF-8
+		data = append(data, 0, 0, 0, 12, 'e', 'n', 'c', 'd', 0, 0, 1, 0)
+		sizes = append(sizes, uint32(len(data)-start))
+	}
+	return data, sizes
+}
+
+// chapterTrak returns the chapter track referenced by the tref boxes of the
+// other tracks. The samples are at offset, duration is the duration of the
+// movie in the movie timescale, 0 if it's unknown.
+func (m *muxer) chapterTrak(offset uint64, co64 bool, movieTimescale uint32, duration uint64) *box {
+	chapters := m.opts.Chapters
+	_, sizes := chapterSamples(chapters)
+	movieEnd := time.Duration(duration) * time.Second / time.Duration(movieTimescale)
+
+	// the first chapter covers the beginning of the movie
+	var stts []byte
+	var mediaDuration uint64
+	for i, chapter := range chapters {
+		end := chapter.end()
+		if i+1 < len(chapters) {
+			end = chapters[i+1].Start
+		} else if end == chapter.Start && movieEnd > end {
+			end = movieEnd
+		}
+		sampleDuration := uint64(end.Milliseconds()) - mediaDuration
+		if end.Milliseconds() <= int64(mediaDuration) {
+			sampleDuration = 1
+		}
+		stts = appendUint32(stts, 1)
+		stts = appendUint32(stts, uint32(sampleDuration))
+		mediaDuration += sampleDuration
+	}
+
+	zeros := func(n int) []byte { return make([]byte, n) }
+	matrix := appendUint32(nil, 0x00010000)
+	matrix = append(matrix, zeros(12)...)
+	matrix = appendUint32(matrix, 0x00010000)
+	matrix = append(matrix, zeros(12)...)
+	matrix = appendUint32(matrix, 0x40000000)
+
+	// the track is in the movie but disabled, so it's not displayed
+	tkhd := appendUint32(zeros(8), m.chapterID())
+	tkhd = appendUint32(append(tkhd, zeros(4)...), uint32(mediaDuration*uint64(movieTimescale)/chapterTimescale))
+	tkhd = append(tkhd, zeros(16)...)
+	tkhd = append(tkhd, matrix...)
+	tkhd = append(tkhd, zeros(8)...)
+
+	mdhd := appendUint32(zeros(8), chapterTimescale)
+	mdhd = appendUint32(mdhd, uint32(mediaDuration))
+	mdhd = appendUint16(mdhd, 0x55C4) // und
+	mdhd = append(mdhd, zeros(2)...)
+
+	hdlr := append(zeros(4), "text"...)
+	hdlr = append(hdlr, zeros(12)...)
+	hdlr = append(hdlr, "Chapters\x00"...)
+
+	gmin := appendUint16(nil, 0x0040) // graphics mode
+	gmin = appendUint16(gmin, 0x8000)
+	gmin = appendUint16(gmin, 0x8000)
+	gmin = appendUint16(gmin, 0x8000)
+	gmin = append(gmin, zeros(4)...)
+	text := appendUint16(nil, 1)
+	text = append(text, zeros(12)...)
+	text = appendUint32(text, 1)
+	text = append(text, zeros(12)...)
+	text = appendUint32(text, 0x00004000)
+	text = append(text, zeros(2)...)
+
+	dref := appendUint32(nil, 1)
+	dref = append(dref, fullBox("url ", 0, 1, nil).bytes()...)
+
+	// the text sample entry is left justified, with default colors and font
+	entry := append(zeros(6), 0, 1)
+	entry = append(entry, zeros(4)...)
+	entry = appendUint32(entry, 1)
+	entry = append(entry, zeros(36)...)
+	stsd := appendUint32(nil, 1)
+	stsd = append(stsd, (&box{typ: "text", data: entry}).bytes()...)
+
+	stsc := appendUint32(nil, 1)
+	stsc = appendUint32(stsc, 1)
+	stsc = appendUint32(stsc, uint32(len(sizes)))
+	stsc = appendUint32(stsc, 1)
+
+	stsz := appendUint32(nil, 0)
+	stsz = appendUint32(stsz, uint32(len(sizes)))
+	for _, size := range sizes {
+		stsz = appendUint32(stsz, size)
+	}
+
+	stco := fullBox("stco", 0, 0, appendUint32(appendUint32(nil, 1), uint32(offset)))
+	if co64 {
+		stco = fullBox("co64", 0, 0, appendUint64(appendUint32(nil, 1), offset))
+	}
+
+	return &box{typ: "trak", children: []*box{
+		fullBox("tkhd", 0, 0x000002, tkhd),
+		{typ: "mdia", children: []*box{
+			fullBox("mdhd", 0, 0, mdhd),
+			fullBox("hdlr", 0, 0, hdlr),
+			{typ: "minf", children: []*box{
+				{typ: "gmhd", children: []*box{
+					fullBox("gmin", 0, 0, gmin),
+					{typ: "text", data: text},
+				}},
+				{typ: "dinf", children: []*box{fullBox("dref", 0, 0, dref)}},
+				{typ: "stbl", children: []*box{
+					fullBox("stsd", 0, 0, stsd),
+					fullBox("stts", 0, 0, append(appendUint32(nil, uint32(len(sizes))), stts...)),
+					fullBox("stsc", 0, 0, stsc),
+					fullBox("stsz", 0, 0, stsz),
+					stco,
+				}},
+			}},
+		}},
+	}}
+}
+
+// chapterID returns the track ID of the chapter track.
+func (m *muxer) chapterID() uint32 {
+	return uint32(len(m.inputs) + 1)
+}
+
+// chapterTref returns the box referencing the chapter track.
+func (m *muxer) chapterTref() *box {
+	return &box{typ: "tref", children: []*box{
+		{typ: "chap", data: appendUint32(nil, m.chapterID())},
+	}}
+}
+
+// chapterUdta returns the user data with the Nero chapter list,
+// it's read by the players ignoring the chapter track.
+func (m *muxer) chapterUdta() *box {
+	chapters := m.opts.Chapters
+	if len(chapters) > 0xFF {
+		chapters = chapters[:0xFF]
+	}
+	chpl := append(make([]byte, 4), byte(len(chapters)))
+	for _, chapter := range chapters {
+		title := truncateUTF8(chapter.Title, 0xFF)
+		// the start is in 100ns units
+		chpl = appendUint64(chpl, uint64(chapter.Start/100))
+		chpl = append(chpl, byte(len(title)))
+		chpl = append(chpl, title...)
+	}
+	return &box{typ: "udta", children: []*box{fullBox("chpl", 1, 0, chpl)}}
+}
+
+// truncateUTF8 truncates s to at most n bytes without splitting a character.
+func truncateUTF8(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
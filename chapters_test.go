@@ -0,0 +1,145 @@
+package vimego
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+	"time"
+)
+
+var testChapters = Chapters{
+	{Title: "Intro", Start: 0, End: 12 * time.Second},
+	{Title: "Verse", Start: 12 * time.Second, End: 62 * time.Second},
+	{Title: "Hold my hand; run", Start: 62 * time.Second, End: 243 * time.Second},
+}
+
+func TestVideoChapters(t *testing.T) {
+	fake := newFakeVimeo(t)
+
+	chapters, err := fake.video().Chapters()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(chapters) != len(testChapters) {
+		t.Fatalf("len(chapters) == %d", len(chapters))
+	}
+	for i, chapter := range chapters {
+		if *chapter != *testChapters[i] {
+			t.Errorf("chapters[%d]: %+v", i, chapter)
+		}
+	}
+}
+
+func TestChaptersVTT(t *testing.T) {
+	chapters := append(Chapters{}, testChapters...)
+	chapters = append(chapters, &Chapter{Title: "<Outro> & credits", Start: time.Hour})
+
+	cues, err := ParseVTT(chapters.VTT())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cues) != 4 {
+		t.Fatalf("len(cues) == %d", len(cues))
+	}
+	expected := Cue{ID: "chapter-2", Start: 12 * time.Second, End: 62 * time.Second, Text: "Verse"}
+	if *cues[1] != expected {
+		t.Errorf("cues[1]: %+v", cues[1])
+	}
+	// the end of the last chapter is unknown
+	expected = Cue{ID: "chapter-4", Start: time.Hour, End: time.Hour, Text: "&lt;Outro&gt; &amp; credits"}
+	if *cues[3] != expected {
+		t.Errorf("cues[3]: %+v", cues[3])
+	}
+}
+
+func TestChaptersFFMetadata(t *testing.T) {
+	expected := ";FFMETADATA1\n" +
+		"\n[CHAPTER]\nTIMEBASE=1/1000\nSTART=0\nEND=12000\ntitle=Intro\n" +
+		"\n[CHAPTER]\nTIMEBASE=1/1000\nSTART=12000\nEND=62000\ntitle=Verse\n" +
+		"\n[CHAPTER]\nTIMEBASE=1/1000\nSTART=62000\nEND=243000\ntitle=Hold my hand\\; run\n"
+	if metadata := string(testChapters.FFMetadata()); metadata != expected {
+		t.Errorf("FFMetadata() == %q", metadata)
+	}
+}
+
+func TestMuxChapters(t *testing.T) {
+	for _, fragmented := range []bool{false, true} {
+		video := newTestFmp4(7, 90000, 3000, 4, 5)
+		audio := newTestFmp4(1, 48000, 1024, 10, 3)
+
+		var out bytes.Buffer
+		err := Mux(&out, bytes.NewReader(video), bytes.NewReader(audio), &MuxOptions{
+			Fragmented: fragmented,
+			Chapters:   testChapters,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		testMuxedChapters(t, out.Bytes(), fragmented)
+	}
+}
+
+func testMuxedChapters(t *testing.T, data []byte, fragmented bool) {
+	var moov *box
+	err := walkBoxes(data, 0, func(typ string, start, payload, end int) error {
+		if typ == "moov" {
+			var err error
+			moov = &box{typ: typ}
+			moov.children, err = parseBoxes(data[payload:end])
+			return err
+		}
+		return nil
+	})
+	if err != nil || moov == nil {
+		t.Fatalf("fragmented: %v, no moov: %v", fragmented, err)
+	}
+
+	var chapterTrak *box
+	for _, trak := range moov.children {
+		if trak.typ != "trak" {
+			continue
+		}
+		trackID := readUint32s(trak.child("tkhd").data)[3]
+		if trackID == 3 {
+			chapterTrak = trak
+			continue
+		}
+		tref, _ := parseBoxes(trak.child("tref").data)
+		if len(tref) != 1 || tref[0].typ != "chap" || readUint32s(tref[0].data)[0] != 3 {
+			t.Errorf("fragmented: %v, track %d has no chapter reference", fragmented, trackID)
+		}
+	}
+	if chapterTrak == nil {
+		t.Fatalf("fragmented: %v, no chapter track", fragmented)
+	}
+	if next := readUint32s(moov.child("mvhd").data)[24]; next != 4 {
+		t.Errorf("fragmented: %v, next_track_ID == %d", fragmented, next)
+	}
+
+	// the titles are in the samples
+	stbl := chapterTrak.path("mdia", "minf", "stbl")
+	stsz := readUint32s(stbl.child("stsz").data)[3:]
+	pos := int(readUint32s(stbl.child("stco").data)[2])
+	for i, size := range stsz {
+		sample := data[pos : pos+int(size)]
+		length := int(binary.BigEndian.Uint16(sample))
+		if title := string(sample[2 : 2+length]); title != testChapters[i].Title {
+			t.Errorf("fragmented: %v, sample %d: %q", fragmented, i, title)
+		}
+		pos += int(size)
+	}
+	stts := readUint32s(stbl.child("stts").data)[2:]
+	if stts[1] != 12000 || stts[3] != 50000 || stts[5] != 181000 {
+		t.Errorf("fragmented: %v, stts == %v", fragmented, stts)
+	}
+
+	// the Nero chapter list
+	udta, _ := parseBoxes(moov.child("udta").data)
+	chpl := udta[0].data
+	if udta[0].typ != "chpl" || chpl[8] != 3 {
+		t.Fatalf("fragmented: %v, invalid chpl", fragmented)
+	}
+	if start := binary.BigEndian.Uint64(chpl[9+14:]); start != 12*10000000 {
+		t.Errorf("fragmented: %v, start of chapter 2 == %d", fragmented, start)
+	}
+}
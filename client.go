@@ -0,0 +1,219 @@
+package vimego
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+)
+
+// Endpoints are the base URLs of the Vimeo services, without trailing slashes.
+type Endpoints struct {
+	// Site serves the video pages.
+	Site string
+	// Player serves the player config.
+	Player string
+	// V2 is the simple API serving the metadata.
+	V2 string
+	// API is the API used by the search.
+	API string
+	// JWT serves the tokens for the API.
+	JWT string
+}
+
+// DefaultEndpoints are used for the empty fields of Endpoints.
+var DefaultEndpoints = Endpoints{
+	Site:   "https://vimeo.com",
+	Player: "https://player.vimeo.com",
+	V2:     "https://vimeo.com/api/v2",
+	API:    "https://api.vimeo.com",
+	JWT:    "https://vimeo.com/_rv/jwt",
+}
+
+func (e *Endpoints) withDefaults() *Endpoints {
+	result := DefaultEndpoints
+	if e == nil {
+		return &result
+	}
+	if e.Site != "" {
+		result.Site = e.Site
+	}
+	if e.Player != "" {
+		result.Player = e.Player
+	}
+	if e.V2 != "" {
+		result.V2 = e.V2
+	}
+	if e.API != "" {
+		result.API = e.API
+	}
+	if e.JWT != "" {
+		result.JWT = e.JWT
+	}
+	return &result
+}
+
+// Client creates Videos and SearchClients sharing its settings.
+type Client struct {
+	Header     map[string][]string
+	HTTPClient *http.Client
+	Endpoints  *Endpoints
+
+	// APILimits apply to the requests to the Endpoints, CDNLimits to the
+	// other ones (the streams). Both are shared by everything the Client
+	// creates and must be set before the first Video or SearchClient.
+	APILimits *Limits
+	CDNLimits *Limits
+
+	// Cache is shared by the Videos.
+	Cache Cache
+
+	once    sync.Once
+	limited *http.Client
+}
+
+// NewClient creates a new Client with default parameters.
+func NewClient() *Client {
+	return &Client{
+		HTTPClient: &http.Client{},
+		Header:     map[string][]string{"User-Agent": {UserAgent}},
+	}
+}
+
+// NewVideo creates a new Video from URL.
+func (c *Client) NewVideo(url string) (*Video, error) {
+	ref, err := ParseURL(url)
+	if err != nil {
+		return nil, err
+	}
+	videoId := int(ref.ID)
+	if int64(videoId) != ref.ID {
+		return nil, fmt.Errorf("%w: the video ID %d is out of range", ErrInvalidUrl, ref.ID)
+	}
+
+	// the canonical URL, the page of the video is requested from it
+	video := c.NewVideoFromId(videoId)
+	if ref.Hash != "" {
+		video.Url += "/" + ref.Hash
+	}
+	video.Hash = ref.Hash
+	return video, nil
+}
+
+// NewVideoFromId creates a new Video from video ID.
+func (c *Client) NewVideoFromId(videoId int) *Video {
+	return &Video{
+		Url:        fmt.Sprintf("%s/%v", c.Endpoints.withDefaults().Site, videoId),
+		VideoId:    videoId,
+		HTTPClient: c.httpClient(),
+		Header:     copyHeader(c.Header),
+		Endpoints:  c.Endpoints,
+		Cache:      c.Cache,
+	}
+}
+
+// NewSearchClient creates a new SearchClient with default parameters.
+func (c *Client) NewSearchClient() *SearchClient {
+	return &SearchClient{
+		PerPage:    18,
+		Filter:     VideoFilter,
+		Order:      RelevanceOrder,
+		Direction:  DescDirection,
+		Category:   AnyCategory,
+		HTTPClient: c.httpClient(),
+		Header:     copyHeader(c.Header),
+		Endpoints:  c.Endpoints,
+	}
+}
+
+// NewShowcase creates a new Showcase from URL.
+func (c *Client) NewShowcase(url string) (*Showcase, error) {
+	showcaseId, err := parseShowcaseURL(url)
+	if err != nil {
+		return nil, err
+	}
+	return c.NewShowcaseFromId(showcaseId), nil
+}
+
+// NewShowcaseFromId creates a new Showcase from showcase ID.
+func (c *Client) NewShowcaseFromId(showcaseId int) *Showcase {
+	return &Showcase{
+		ID:         showcaseId,
+		PerPage:    100,
+		HTTPClient: c.httpClient(),
+		Header:     copyHeader(c.Header),
+		Endpoints:  c.Endpoints,
+		Cache:      c.Cache,
+	}
+}
+
+// NewUser creates a new User from the profile URL or the name in it.
+func (c *Client) NewUser(url string) (*User, error) {
+	name, err := parseListingName(url, "")
+	if err != nil {
+		return nil, err
+	}
+	user := &User{}
+	c.initListing(&user.listing, "/users", name)
+	return user, nil
+}
+
+// NewChannel creates a new Channel from the channel URL or the name in it.
+func (c *Client) NewChannel(url string) (*Channel, error) {
+	name, err := parseListingName(url, "channels")
+	if err != nil {
+		return nil, err
+	}
+	channel := &Channel{}
+	c.initListing(&channel.listing, "/channels", name)
+	return channel, nil
+}
+
+// NewGroup creates a new Group from the group URL or the name in it.
+func (c *Client) NewGroup(url string) (*Group, error) {
+	name, err := parseListingName(url, "groups")
+	if err != nil {
+		return nil, err
+	}
+	group := &Group{}
+	c.initListing(&group.listing, "/groups", name)
+	return group, nil
+}
+
+// initListing sets the settings of a User, a Channel or a Group.
+func (c *Client) initListing(l *listing, prefix, name string) {
+	l.Name = name
+	l.PerPage = 100
+	l.HTTPClient = c.httpClient()
+	l.Header = copyHeader(c.Header)
+	l.Endpoints = c.Endpoints
+	l.Cache = c.Cache
+	l.prefix = prefix
+}
+
+// httpClient returns HTTPClient with the limits applied.
+func (c *Client) httpClient() *http.Client {
+	c.once.Do(func() {
+		c.limited = c.HTTPClient
+		if c.APILimits == nil && c.CDNLimits == nil {
+			return
+		}
+		limited := http.Client{}
+		if c.HTTPClient != nil {
+			limited = *c.HTTPClient
+		}
+		limited.Transport = newLimitedTransport(
+			limited.Transport, c.Endpoints.withDefaults(), c.APILimits, c.CDNLimits,
+		)
+		c.limited = &limited
+	})
+	return c.limited
+}
+
+// copyHeader returns a copy of the header, so requests may modify it.
+func copyHeader(header map[string][]string) map[string][]string {
+	result := make(map[string][]string, len(header))
+	for key, values := range header {
+		result[key] = append([]string(nil), values...)
+	}
+	return result
+}
@@ -0,0 +1,53 @@
+package vimego
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestClientEndpoints(t *testing.T) {
+	fake := newFakeVimeo(t)
+
+	client := NewClient()
+	client.HTTPClient = &http.Client{}
+	client.Header["X-Test"] = []string{"1"}
+	client.Endpoints = &Endpoints{
+		Site:   fake.server.URL,
+		Player: fake.server.URL,
+		V2:     fake.server.URL + "/api/v2",
+		API:    fake.server.URL,
+		JWT:    fake.server.URL + "/_rv/jwt",
+	}
+
+	video := client.NewVideoFromId(testVideoId)
+	if video.Url != fake.server.URL+"/206152466" {
+		t.Errorf("video.Url == %q", video.Url)
+	}
+	if _, err := video.Metadata(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := video.Formats(); err != nil {
+		t.Fatal(err)
+	}
+
+	search := client.NewSearchClient()
+	if _, err := search.Search("Crystal Castles", 1); err != nil {
+		t.Fatal(err)
+	}
+
+	// the header is copied
+	video.Header["X-Test"] = []string{"2"}
+	if client.Header["X-Test"][0] != "1" || search.Header["Authorization"] != nil {
+		t.Error("the header was modified")
+	}
+}
+
+func TestEndpointsDefaults(t *testing.T) {
+	endpoints := (&Endpoints{API: "http://localhost"}).withDefaults()
+	if endpoints.API != "http://localhost" || endpoints.Player != DefaultEndpoints.Player {
+		t.Errorf("endpoints: %+v", endpoints)
+	}
+	if *(*Endpoints)(nil).withDefaults() != DefaultEndpoints {
+		t.Error("nil endpoints don't match the defaults")
+	}
+}
@@ -1,11 +1,11 @@
 package vimego
 
 import (
-	"bytes"
+	"context"
 	"encoding/base64"
 	"io"
 	"net/http"
-	"net/url"
+	"time"
 )
 
 type DashStreams struct {
@@ -95,70 +95,115 @@ type DashStream struct {
 	MaxSegmentDuration int            `json:"max_segment_duration"`
 	InitSegment        string         `json:"init_segment"`
 	Segments           []*DashSegment `json:"segments"`
+
+	// httpClient is the client of the video, used if the options have none.
+	httpClient *http.Client
 }
 
-// Readers returns an io.ReadCloser for reading streaming data.
+// Reader returns an io.ReadCloser for reading streaming data.
+// If httpClient is nil, the client of the video is used.
 func (s *DashStream) Reader(httpClient *http.Client) (io.ReadCloser, int64, error) {
-	if httpClient == nil {
-		httpClient = http.DefaultClient
-	}
-	req, _ := http.NewRequest("GET", "", nil)
+	return s.ReaderWithOptions(&ReaderOptions{HTTPClient: httpClient})
+}
 
-	r, w := io.Pipe()
-	var length int64
+// ReaderWithOptions returns an io.ReadCloser for reading streaming data.
+// The length is the number of bytes left after StartTime and Offset.
+func (s *DashStream) ReaderWithOptions(opts *ReaderOptions) (io.ReadCloser, int64, error) {
+	return s.ReaderContext(context.Background(), opts)
+}
 
-	initSegment, err := base64.StdEncoding.DecodeString(s.InitSegment)
+// ReaderContext is like ReaderWithOptions, cancelling ctx stops the download.
+func (s *DashStream) ReaderContext(ctx context.Context, opts *ReaderOptions) (io.ReadCloser, int64, error) {
+	opts = opts.withClient(s.httpClient)
+	segments, err := s.segments(opts)
 	if err != nil {
 		return nil, 0, err
 	}
-
-	length += int64(len(initSegment))
-	for _, chunk := range s.Segments {
-		length += int64(chunk.Size)
+	refresher := newUrlRefresher(opts.withDefaults().Refresh, s.URL)
+	return readSegments(ctx, opts, segments, refresher), segmentsLength(segments), nil
+}
+
+// ReadSeeker returns an io.ReadSeekCloser for reading streaming data.
+// Every Seek restarts the download at the new position.
+// The Offset of the options is ignored. ErrSeekUnsupported is returned
+// if the size of some segments is unknown.
+func (s *DashStream) ReadSeeker(opts *ReaderOptions) (io.ReadSeekCloser, error) {
+	var o ReaderOptions
+	if opts != nil {
+		o = *opts
+	}
+	if o.HTTPClient == nil {
+		o.HTTPClient = s.httpClient
 	}
+	o.Offset = 0
 
-	loadChunk := func(chunkUrl string) error {
-		newUrl, err := url.Parse(chunkUrl)
-		if err != nil {
-			return err
-		}
-		req.URL = newUrl
+	segments, err := s.segments(&o)
+	if err != nil {
+		return nil, err
+	}
+	length := segmentsLength(segments)
+	if length < 0 {
+		return nil, ErrSeekUnsupported
+	}
 
-		resp, err := httpClient.Do(req)
-		if err != nil {
-			return err
+	return &segmentReadSeeker{
+		open: func(offset int64) (io.ReadCloser, error) {
+			o := o
+			o.Offset = offset
+			reader, _, err := s.ReaderWithOptions(&o)
+			return reader, err
+		},
+		length: length,
+	}, nil
+}
+
+// OffsetAt returns the position in bytes of the segment containing
+// the given time in seconds.
+func (s *DashStream) OffsetAt(t float64) int64 {
+	initSegment, _ := base64.StdEncoding.DecodeString(s.InitSegment)
+	offset := int64(len(initSegment))
+	for _, chunk := range s.Segments {
+		if chunk.End > t {
+			break
 		}
-		defer resp.Body.Close()
+		offset += int64(chunk.Size)
+	}
+	return offset
+}
 
-		if resp.StatusCode >= 400 {
-			return ErrUnexpectedStatusCode(resp.StatusCode)
-		}
+// Expires returns when the signed URL of the stream expires, zero if unknown.
+func (s *DashStream) Expires() time.Time {
+	return urlExpiry([]byte(s.URL))
+}
 
-		_, err = io.Copy(w, resp.Body)
-		return err
+func (s *DashStream) segments(opts *ReaderOptions) ([]*segment, error) {
+	initSegment, err := base64.StdEncoding.DecodeString(s.InitSegment)
+	if err != nil {
+		return nil, err
 	}
 
-	go func() {
-		// load the init chunk
-		_, err := io.Copy(w, bytes.NewReader(initSegment))
-		if err != nil {
-			_ = w.CloseWithError(err)
-			return
-		}
+	var startTime float64
+	var offset int64
+	if opts != nil {
+		startTime, offset = opts.StartTime, opts.Offset
+	}
 
-		// load all the chunks
-		for _, chunk := range s.Segments {
-			err := loadChunk(s.URL + chunk.URL)
-			if err != nil {
-				_ = w.CloseWithError(err)
-				return
-			}
+	segments := make([]*segment, 0, len(s.Segments)+1)
+	segments = append(segments, &segment{
+		data: initSegment,
+		size: int64(len(initSegment)),
+	})
+	for _, chunk := range s.Segments {
+		if startTime > 0 && chunk.End <= startTime {
+			continue
 		}
+		segments = append(segments, &segment{
+			url:  s.URL + chunk.URL,
+			size: int64(chunk.Size),
+		})
+	}
 
-		w.Close()
-	}()
-
-	return r, length, nil
+	return skipBytes(segments, offset)
 }
 
 type DashVideoStream struct {
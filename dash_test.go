@@ -0,0 +1,317 @@
+package vimego
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// newTestDashStream returns a stream of n segments served by the server.
+// The content of the segment i is "[i]".
+func newTestDashStream(serverUrl string, n int) (*DashStream, []byte) {
+	stream := &DashStream{
+		URL:         serverUrl + "/",
+		InitSegment: base64.StdEncoding.EncodeToString([]byte("init")),
+	}
+	expected := []byte("init")
+	for i := 0; i < n; i++ {
+		data := fmt.Sprintf("[%d]", i)
+		stream.Segments = append(stream.Segments, &DashSegment{
+			URL:  fmt.Sprintf("segment-%d.m4s", i),
+			Size: len(data),
+		})
+		expected = append(expected, data...)
+	}
+	return stream, expected
+}
+
+func TestDashReaderConcurrent(t *testing.T) {
+	var mu sync.Mutex
+	var active, peak int
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		active++
+		if active > peak {
+			peak = active
+		}
+		mu.Unlock()
+
+		i, _ := strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/segment-"), ".m4s"))
+		// the later segments arrive first
+		time.Sleep(time.Duration(10-i%10) * time.Millisecond)
+		fmt.Fprintf(w, "[%d]", i)
+
+		mu.Lock()
+		active--
+		mu.Unlock()
+	}))
+	defer server.Close()
+
+	stream, expected := newTestDashStream(server.URL, 30)
+	reader, length, err := stream.ReaderWithOptions(&ReaderOptions{
+		HTTPClient:    server.Client(),
+		Concurrency:   4,
+		MaxBufferSize: 16,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reader.Close()
+
+	if length != int64(len(expected)) {
+		t.Errorf("length == %d, expected %d", length, len(expected))
+	}
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(data, expected) {
+		t.Errorf("data == %q", data)
+	}
+	if peak < 2 {
+		t.Errorf("segments weren't downloaded in parallel, peak == %d", peak)
+	}
+}
+
+func TestDashReaderError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/segment-3.m4s" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		io.WriteString(w, "data")
+	}))
+	defer server.Close()
+
+	stream, _ := newTestDashStream(server.URL, 10)
+	reader, _, err := stream.ReaderWithOptions(&ReaderOptions{
+		HTTPClient:  server.Client(),
+		Concurrency: 3,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reader.Close()
+
+	_, err = io.ReadAll(reader)
+	if err != ErrUnexpectedStatusCode(http.StatusNotFound) {
+		t.Errorf("err == %v", err)
+	}
+}
+
+func TestDashReaderRetry(t *testing.T) {
+	var mu sync.Mutex
+	attempts := map[string]int{}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		attempts[r.URL.Path]++
+		attempt := attempts[r.URL.Path]
+		mu.Unlock()
+
+		switch {
+		case r.URL.Path == "/segment-1.m4s" && attempt == 1:
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusServiceUnavailable)
+		case r.URL.Path == "/segment-2.m4s" && attempt == 1:
+			// write a part of the segment and drop the connection
+			w.Header().Set("Content-Length", "100")
+			io.WriteString(w, "[2")
+			w.(http.Flusher).Flush()
+			conn, _, _ := w.(http.Hijacker).Hijack()
+			conn.Close()
+		case r.URL.Path == "/segment-3.m4s" && attempt <= 2:
+			w.WriteHeader(http.StatusBadGateway)
+		default:
+			io.WriteString(w, strings.TrimSuffix(strings.Replace(r.URL.Path, "/segment-", "[", 1), ".m4s")+"]")
+		}
+	}))
+	defer server.Close()
+
+	stream, expected := newTestDashStream(server.URL, 5)
+	reader, _, err := stream.ReaderWithOptions(&ReaderOptions{
+		HTTPClient:  server.Client(),
+		Concurrency: 2,
+		RetryDelay:  time.Millisecond,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reader.Close()
+
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(data, expected) {
+		t.Errorf("data == %q", data)
+	}
+}
+
+func TestDashReaderContext(t *testing.T) {
+	stopped := make(chan struct{}, 4)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/segment-0.m4s" {
+			io.WriteString(w, "[0]")
+			return
+		}
+		// the other segments never arrive
+		<-r.Context().Done()
+		stopped <- struct{}{}
+	}))
+	defer server.Close()
+
+	stream, _ := newTestDashStream(server.URL, 10)
+	ctx, cancel := context.WithCancel(context.Background())
+	reader, _, err := stream.ReaderContext(ctx, &ReaderOptions{
+		HTTPClient:  server.Client(),
+		Concurrency: 4,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reader.Close()
+
+	data := make([]byte, 7)
+	if _, err := io.ReadFull(reader, data); err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "init[0]" {
+		t.Errorf("data == %q", data)
+	}
+
+	cancel()
+	if _, err := reader.Read(data); err != context.Canceled {
+		t.Errorf("err == %v", err)
+	}
+	select {
+	case <-stopped:
+	case <-time.After(5 * time.Second):
+		t.Error("the requests weren't cancelled")
+	}
+}
+
+func TestDashReaderClose(t *testing.T) {
+	started := make(chan struct{}, 4)
+	stopped := make(chan struct{}, 4)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		started <- struct{}{}
+		<-r.Context().Done()
+		stopped <- struct{}{}
+	}))
+	defer server.Close()
+
+	stream, _ := newTestDashStream(server.URL, 10)
+	reader, _, err := stream.ReaderWithOptions(&ReaderOptions{
+		HTTPClient:  server.Client(),
+		Concurrency: 4,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	<-started
+	reader.Close()
+
+	select {
+	case <-stopped:
+	case <-time.After(5 * time.Second):
+		t.Error("the requests weren't cancelled")
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	if d := parseRetryAfter("3"); d != 3*time.Second {
+		t.Errorf("parseRetryAfter(\"3\") == %v", d)
+	}
+	date := time.Now().Add(time.Minute).UTC().Format(http.TimeFormat)
+	if d := parseRetryAfter(date); d <= 0 || d > time.Minute {
+		t.Errorf("parseRetryAfter(%q) == %v", date, d)
+	}
+	if d := parseRetryAfter("soon"); d != 0 {
+		t.Errorf("parseRetryAfter(\"soon\") == %v", d)
+	}
+}
+
+func TestDashReaderOffset(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data := strings.TrimSuffix(strings.Replace(r.URL.Path, "/segment-", "[", 1), ".m4s") + "]"
+		http.ServeContent(w, r, "", time.Time{}, strings.NewReader(data))
+	}))
+	defer server.Close()
+
+	stream, expected := newTestDashStream(server.URL, 12)
+	for i, chunk := range stream.Segments {
+		chunk.Start, chunk.End = float64(i*6), float64(i*6+6)
+	}
+
+	for _, offset := range []int64{0, 2, 4, 5, 30, int64(len(expected))} {
+		reader, length, err := stream.ReaderWithOptions(&ReaderOptions{
+			HTTPClient: server.Client(),
+			Offset:     offset,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := io.ReadAll(reader)
+		reader.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(data, expected[offset:]) {
+			t.Errorf("offset %d: data == %q", offset, data)
+		}
+		if length != int64(len(data)) {
+			t.Errorf("offset %d: length == %d", offset, length)
+		}
+	}
+
+	reader, _, err := stream.ReaderWithOptions(&ReaderOptions{
+		HTTPClient: server.Client(),
+		StartTime:  61,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := io.ReadAll(reader)
+	reader.Close()
+	if string(data) != "init[10][11]" {
+		t.Errorf("data == %q", data)
+	}
+
+	if offset := stream.OffsetAt(61); !bytes.HasPrefix(expected[offset:], []byte("[10]")) {
+		t.Errorf("stream.OffsetAt(61) == %d", offset)
+	}
+
+	seeker, err := stream.ReadSeeker(&ReaderOptions{HTTPClient: server.Client()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer seeker.Close()
+
+	buf := make([]byte, 4)
+	if _, err := io.ReadFull(seeker, buf); err != nil || string(buf) != "init" {
+		t.Errorf("buf == %q, err == %v", buf, err)
+	}
+	if _, err := seeker.Seek(-5, io.SeekEnd); err != nil {
+		t.Fatal(err)
+	}
+	data, _ = io.ReadAll(seeker)
+	if string(data) != "][11]" {
+		t.Errorf("data == %q", data)
+	}
+
+	stream.Segments[3].Size = 0
+	if _, err := stream.ReadSeeker(&ReaderOptions{HTTPClient: server.Client()}); err != ErrSeekUnsupported {
+		t.Errorf("unknown size: err == %v", err)
+	}
+}
@@ -0,0 +1,240 @@
+package vimego
+
+import (
+	"context"
+	"io"
+	"strings"
+)
+
+// DownloadOptions are used by Video.Download.
+type DownloadOptions struct {
+	// MaxHeight limits the height of the video, 0 means no limit.
+	MaxHeight int
+	// Container is the preferred container, e.g. "mp4".
+	// Other containers are used if no format matches it.
+	Container string
+	// Preference chooses between progressive and DASH formats.
+	// BestQualityPreference picks the highest resolution, progressive on a tie.
+	// DASH isn't chosen over a progressive format if it has no MP4 audio.
+	Preference FormatPreference
+	// AudioOnly downloads the best DASH audio stream alone.
+	AudioOnly bool
+
+	// Fragmented and TempDir are used when DASH streams are muxed, see MuxOptions.
+	Fragmented bool
+	TempDir    string
+	// Chapters adds the chapters of the video when DASH streams are muxed.
+	Chapters bool
+
+	// Reader is used for every stream. If its HTTPClient is nil,
+	// Video.HTTPClient is used. If its Refresh is nil, the expired URLs
+	// are refreshed with Video.ProgressiveRefresh and Video.DashRefresh.
+	// The Progress of muxed DASH streams is the sum of both.
+	Reader *ReaderOptions
+}
+
+// download is a format chosen by Video.Download.
+type download struct {
+	progressive *ProgressiveFormat
+	video       *DashVideoStream
+	audio       *DashAudioStream
+}
+
+// Download chooses the best format matching the options and writes it to dst.
+// DASH streams are muxed into a single MP4, see MuxDash.
+func (v *Video) Download(ctx context.Context, dst io.Writer, opts *DownloadOptions) error {
+	if opts == nil {
+		opts = &DownloadOptions{}
+	}
+	readerOpts := ReaderOptions{}
+	if opts.Reader != nil {
+		readerOpts = *opts.Reader
+	}
+	if readerOpts.HTTPClient == nil {
+		readerOpts.HTTPClient = v.HTTPClient
+	}
+
+	formats, err := v.FormatsContext(ctx)
+	if err != nil {
+		return err
+	}
+	d, err := v.chooseDownload(ctx, formats, opts)
+	if err != nil {
+		return err
+	}
+
+	if d.progressive != nil {
+		if readerOpts.Refresh == nil {
+			readerOpts.Refresh = v.ProgressiveRefresh(d.progressive)
+		}
+		reader, _, err := d.progressive.ReaderContext(ctx, &readerOpts)
+		if err != nil {
+			return err
+		}
+		defer reader.Close()
+		_, err = io.Copy(dst, reader)
+		return err
+	}
+
+	var chapters Chapters
+	if opts.Chapters {
+		chapters, err = v.ChaptersContext(ctx)
+		if err != nil {
+			return err
+		}
+	}
+
+	var openVideo, openAudio openFunc
+	if d.video != nil {
+		openVideo = v.dashOpener(&d.video.DashStream)
+	}
+	if d.audio != nil {
+		openAudio = v.dashOpener(&d.audio.DashStream)
+	}
+	return muxStreams(ctx, dst, openVideo, openAudio, &MuxOptions{
+		Fragmented: opts.Fragmented,
+		TempDir:    opts.TempDir,
+		Chapters:   chapters,
+		Reader:     &readerOpts,
+	})
+}
+
+// dashOpener returns an openFunc refreshing the URL of the stream
+// once it expires, unless the options have their own Refresh.
+func (v *Video) dashOpener(s *DashStream) openFunc {
+	return func(ctx context.Context, opts *ReaderOptions) (io.ReadCloser, int64, error) {
+		// the defaults are applied by the reader, applying them
+		// twice would turn the disabled retries into the default ones
+		o := ReaderOptions{}
+		if opts != nil {
+			o = *opts
+		}
+		if o.Refresh == nil {
+			o.Refresh = v.DashRefresh(s)
+		}
+		return s.ReaderContext(ctx, &o)
+	}
+}
+
+func (v *Video) chooseDownload(ctx context.Context, formats *VideoFormats, opts *DownloadOptions) (*download, error) {
+	var progressive *ProgressiveFormat
+	if !opts.AudioOnly {
+		progressive = chooseProgressive(formats.Progressive, opts)
+	}
+	if progressive != nil && opts.Preference == ProgressivePreference {
+		return &download{progressive: progressive}, nil
+	}
+
+	if formats.Dash == nil || formats.Dash.Url() == "" {
+		if progressive != nil {
+			return &download{progressive: progressive}, nil
+		}
+		return nil, ErrNoFormats
+	}
+	streams, err := v.GetDashStreamsContext(ctx, formats.Dash.Url())
+	if err != nil {
+		if progressive != nil {
+			return &download{progressive: progressive}, nil
+		}
+		return nil, err
+	}
+
+	audio := chooseDashAudio(streams.Audio)
+	if opts.AudioOnly {
+		if audio == nil {
+			return nil, ErrNoFormats
+		}
+		return &download{audio: audio}, nil
+	}
+
+	video := chooseDashVideo(streams.Video, opts)
+	if audio == nil && progressive != nil {
+		// a silent DASH video isn't better than a progressive file
+		video = nil
+	}
+	switch {
+	case video == nil && progressive == nil:
+		return nil, ErrNoFormats
+	case video == nil:
+		return &download{progressive: progressive}, nil
+	case progressive == nil || opts.Preference == DashPreference:
+		return &download{video: video, audio: audio}, nil
+	}
+
+	// DASH streams are always muxed into MP4
+	if opts.Container != "" && opts.Container != "mp4" && mimeContainer(progressive.Mime) == opts.Container {
+		return &download{progressive: progressive}, nil
+	}
+	if video.Height > progressive.Height {
+		return &download{video: video, audio: audio}, nil
+	}
+	return &download{progressive: progressive}, nil
+}
+
+// chooseProgressive returns the highest resolution format within MaxHeight,
+// preferring the formats in the preferred container.
+func chooseProgressive(formats ProgressiveFormats, opts *DownloadOptions) *ProgressiveFormat {
+	var best, bestMatching *ProgressiveFormat
+	for _, format := range formats {
+		if opts.MaxHeight > 0 && format.Height > opts.MaxHeight {
+			continue
+		}
+		if best == nil || format.Height >= best.Height {
+			best = format
+		}
+		if mimeContainer(format.Mime) != opts.Container {
+			continue
+		}
+		if bestMatching == nil || format.Height >= bestMatching.Height {
+			bestMatching = format
+		}
+	}
+	if bestMatching != nil {
+		return bestMatching
+	}
+	return best
+}
+
+// chooseDashVideo returns the highest resolution stream within MaxHeight,
+// the one with the highest bitrate on a tie. Only MP4 streams can be muxed.
+func chooseDashVideo(streams DashVideoStreams, opts *DownloadOptions) *DashVideoStream {
+	var best *DashVideoStream
+	for _, stream := range streams {
+		if opts.MaxHeight > 0 && stream.Height > opts.MaxHeight {
+			continue
+		}
+		if !isMuxable(stream.MimeType) {
+			continue
+		}
+		if best == nil || stream.Height > best.Height ||
+			stream.Height == best.Height && stream.Bitrate >= best.Bitrate {
+			best = stream
+		}
+	}
+	return best
+}
+
+// chooseDashAudio returns the MP4 stream with the highest bitrate.
+func chooseDashAudio(streams DashAudioStreams) *DashAudioStream {
+	for i := len(streams) - 1; i >= 0; i-- {
+		if isMuxable(streams[i].MimeType) {
+			return streams[i]
+		}
+	}
+	return nil
+}
+
+// mimeContainer returns the subtype of a MIME type, e.g. "mp4" for "video/mp4".
+func mimeContainer(mime string) string {
+	if i := strings.IndexByte(mime, ';'); i >= 0 {
+		mime = mime[:i]
+	}
+	if i := strings.IndexByte(mime, '/'); i >= 0 {
+		mime = mime[i+1:]
+	}
+	return strings.TrimSpace(mime)
+}
+
+func isMuxable(mime string) bool {
+	return mime == "" || mimeContainer(mime) == "mp4"
+}
@@ -0,0 +1,200 @@
+package vimego
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+// newTestDownloadServer serves a video with progressive formats up to 720p
+// and DASH streams up to 1080p.
+func newTestDownloadServer() *httptest.Server {
+	video := newTestFmp4(1, 90000, 3000, 2, 2)
+	audio := newTestFmp4(1, 48000, 1024, 2, 2)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/video/1/config", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"request": {"files": {
+			"progressive": [
+				{"profile": "1", "width": 640, "height": 360, "mime": "video/mp4", "url": "http://vimeo.test/360.mp4"},
+				{"profile": "2", "width": 1280, "height": 720, "mime": "video/mp4", "url": "http://vimeo.test/720.mp4"}
+			],
+			"dash": {"default_cdn": "fastly_skyfire", "cdns": {"fastly_skyfire": {"url": "http://vimeo.test/dash/master.json"}}}
+		}}}`)
+	})
+	mux.HandleFunc("/dash/master.json", func(w http.ResponseWriter, r *http.Request) {
+		stream := func(name string, data []byte) string {
+			return fmt.Sprintf(
+				`"base_url": "%s/", "mime_type": "video/mp4", "segments": [{"url": "data", "size": %d}]`,
+				name, len(data),
+			)
+		}
+		fmt.Fprintf(w, `{"base_url": "../", "video": [
+			{"height": 720, "bitrate": 1, %s},
+			{"height": 1080, "bitrate": 2, %s}
+		], "audio": [{"bitrate": 1, %s}]}`, stream("v720", video), stream("v1080", video), stream("audio", audio))
+	})
+	mux.HandleFunc("/v720/data", func(w http.ResponseWriter, r *http.Request) { w.Write(video) })
+	mux.HandleFunc("/v1080/data", func(w http.ResponseWriter, r *http.Request) { w.Write(video) })
+	mux.HandleFunc("/audio/data", func(w http.ResponseWriter, r *http.Request) { w.Write(audio) })
+	mux.HandleFunc("/360.mp4", func(w http.ResponseWriter, r *http.Request) { io.WriteString(w, "360p") })
+	mux.HandleFunc("/720.mp4", func(w http.ResponseWriter, r *http.Request) { io.WriteString(w, "720p") })
+	return httptest.NewServer(mux)
+}
+
+func TestDownload(t *testing.T) {
+	server := newTestDownloadServer()
+	defer server.Close()
+
+	video := &Video{VideoId: 1, HTTPClient: newTestClient(server)}
+
+	tests := []struct {
+		name     string
+		opts     *DownloadOptions
+		expected string // the progressive format, empty for DASH
+		tracks   int
+	}{
+		{"best", nil, "", 2},
+		{"max height", &DownloadOptions{MaxHeight: 720}, "720p", 0},
+		{"dash", &DownloadOptions{MaxHeight: 720, Preference: DashPreference}, "", 2},
+		{"progressive", &DownloadOptions{Preference: ProgressivePreference}, "720p", 0},
+		{"container", &DownloadOptions{MaxHeight: 480, Container: "webm"}, "360p", 0},
+		{"audio only", &DownloadOptions{AudioOnly: true, Fragmented: true}, "", 1},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var out bytes.Buffer
+			err := video.Download(context.Background(), &out, test.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if test.expected != "" {
+				if out.String() != test.expected {
+					t.Errorf("downloaded %q", out.String())
+				}
+				return
+			}
+
+			tracks := strings.Count(out.String(), "trak")
+			if test.opts != nil && test.opts.Fragmented {
+				tracks = strings.Count(out.String(), "trex")
+			}
+			if tracks != test.tracks {
+				t.Errorf("%d tracks", tracks)
+			}
+		})
+	}
+}
+
+func TestDownloadNoFormats(t *testing.T) {
+	server := newTestDownloadServer()
+	defer server.Close()
+
+	video := &Video{VideoId: 1, HTTPClient: newTestClient(server)}
+	err := video.Download(context.Background(), io.Discard, &DownloadOptions{
+		MaxHeight:  240,
+		Preference: DashPreference,
+	})
+	if err != ErrNoFormats {
+		t.Errorf("err == %v", err)
+	}
+}
+
+func TestDownloadNoRetries(t *testing.T) {
+	var requests int32
+	server := newTestDownloadServer()
+	defer server.Close()
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/data") {
+			atomic.AddInt32(&requests, 1)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		// the config and the DASH JSON
+		server.Config.Handler.ServeHTTP(w, r)
+	}))
+	defer failing.Close()
+
+	video := &Video{VideoId: 1, HTTPClient: newTestClient(failing)}
+	err := video.Download(context.Background(), io.Discard, &DownloadOptions{
+		AudioOnly: true,
+		Reader:    &ReaderOptions{Retries: -1},
+	})
+	if !errors.Is(err, ErrUnexpectedStatusCode(http.StatusInternalServerError)) {
+		t.Errorf("err == %v", err)
+	}
+	if n := atomic.LoadInt32(&requests); n != 1 {
+		t.Errorf("the segment was requested %d times", n)
+	}
+}
+
+func TestDownloadProgress(t *testing.T) {
+	server := newTestDownloadServer()
+	defer server.Close()
+
+	video := &Video{VideoId: 1, HTTPClient: newTestClient(server)}
+	// not safe for concurrent use, the calls must be serialized
+	var reports []Progress
+	err := video.Download(context.Background(), io.Discard, &DownloadOptions{
+		Preference: DashPreference,
+		Reader: &ReaderOptions{
+			Progress: func(p Progress) {
+				reports = append(reports, p)
+			},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	size := int64(len(newTestFmp4(1, 90000, 3000, 2, 2)) + len(newTestFmp4(1, 48000, 1024, 2, 2)))
+	if len(reports) == 0 {
+		t.Fatal("progress wasn't reported")
+	}
+	var done int64
+	for _, p := range reports {
+		if p.BytesDone < done {
+			t.Fatalf("reports: %+v", reports)
+		}
+		done = p.BytesDone
+	}
+	// the streams of the test server have no init segment, so no size
+	if last := reports[len(reports)-1]; last.BytesDone != size || last.Segments != 4 {
+		t.Errorf("last report: %+v", last)
+	}
+}
+
+func TestDownloadNoDashAudio(t *testing.T) {
+	server := newTestDownloadServer()
+	defer server.Close()
+	// the audio stream can't be muxed
+	webm := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/dash/master.json" {
+			server.Config.Handler.ServeHTTP(w, r)
+			return
+		}
+		rec := httptest.NewRecorder()
+		server.Config.Handler.ServeHTTP(rec, r)
+		i := strings.Index(rec.Body.String(), `"audio"`)
+		io.WriteString(w, rec.Body.String()[:i]+strings.ReplaceAll(rec.Body.String()[i:], "video/mp4", "audio/webm"))
+	}))
+	defer webm.Close()
+
+	video := &Video{VideoId: 1, HTTPClient: newTestClient(webm)}
+	for _, opts := range []*DownloadOptions{nil, {Preference: DashPreference}} {
+		var out bytes.Buffer
+		if err := video.Download(context.Background(), &out, opts); err != nil {
+			t.Fatal(err)
+		}
+		if out.String() != "720p" {
+			t.Errorf("opts %+v: downloaded %d bytes instead of the progressive file", opts, out.Len())
+		}
+	}
+}
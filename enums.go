@@ -57,3 +57,25 @@ const (
 	VideoSchoolCategory            SearchCategory = "videoschool"
 	WeedingCategory                SearchCategory = "wedding"
 )
+
+type FormatPreference string
+
+const (
+	BestQualityPreference FormatPreference = ""
+	ProgressivePreference FormatPreference = "progressive"
+	DashPreference        FormatPreference = "dash"
+)
+
+type URLKind string
+
+const (
+	VideoURL    URLKind = "video"
+	PlayerURL   URLKind = "player"
+	ChannelURL  URLKind = "channel"
+	GroupURL    URLKind = "group"
+	ShowcaseURL URLKind = "showcase"
+	AlbumURL    URLKind = "album"
+	OnDemandURL URLKind = "ondemand"
+	ManageURL   URLKind = "manage"
+	ReviewURL   URLKind = "review"
+)
@@ -8,6 +8,16 @@ import (
 var (
 	ErrInvalidUrl    = errors.New("the URL is invalid")
 	ErrParsingFailed = errors.New("couldn't get config")
+
+	ErrPasswordRequired = errors.New("the video is password protected")
+	ErrWrongPassword    = errors.New("the password is wrong")
+
+	ErrInvalidPlaylist = errors.New("the playlist is invalid")
+	ErrSeekUnsupported = errors.New("the stream size is unknown")
+	ErrInvalidMP4      = errors.New("the MP4 stream is invalid")
+	ErrNoFormats       = errors.New("no suitable formats")
+	ErrInvalidVTT      = errors.New("the WebVTT file is invalid")
+	ErrNoStoryboard    = errors.New("the video has no storyboard")
 )
 
 type ErrUnexpectedStatusCode int
@@ -0,0 +1,161 @@
+package vimego
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// signedUrlMargin is subtracted from the expiry of signed URLs,
+// so the cached URLs stay valid long enough to be used.
+const signedUrlMargin = 5 * time.Minute
+
+// signedUrlPatterns match the expiry of the Akamai and Fastly signed URLs.
+var signedUrlPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`exp=(\d+)~`),
+	regexp.MustCompile(`/(\d{10})-0x[0-9a-f]+/`),
+}
+
+// urlExpiry returns the earliest expiry of the signed URLs found in data.
+// It returns zero if there are none.
+func urlExpiry(data []byte) time.Time {
+	var earliest time.Time
+	for _, pattern := range signedUrlPatterns {
+		for _, match := range pattern.FindAllSubmatch(data, -1) {
+			seconds, err := strconv.ParseInt(string(match[1]), 10, 64)
+			if err != nil {
+				continue
+			}
+			expires := time.Unix(seconds, 0)
+			if earliest.IsZero() || expires.Before(earliest) {
+				earliest = expires
+			}
+		}
+	}
+	return earliest
+}
+
+// signedExpiry is like urlExpiry but subtracts signedUrlMargin.
+func signedExpiry(data []byte) time.Time {
+	expires := urlExpiry(data)
+	if expires.IsZero() {
+		return expires
+	}
+	return expires.Add(-signedUrlMargin)
+}
+
+// RefreshFunc returns the new URL of a stream whose URL expired.
+type RefreshFunc func(ctx context.Context) (string, error)
+
+// isRejectedUrl reports whether the server rejected the URL,
+// as it does when a signed URL expires.
+func isRejectedUrl(err error) bool {
+	var statusErr ErrUnexpectedStatusCode
+	if errors.As(err, &statusErr) {
+		return statusErr == http.StatusForbidden || statusErr == http.StatusGone
+	}
+	return false
+}
+
+// urlRefresher replaces the base URL of a stream once it's refreshed.
+// All its methods are no-op for a nil refresher.
+type urlRefresher struct {
+	fn       RefreshFunc
+	original string
+
+	mu   sync.Mutex
+	base string
+	gen  int // the number of refreshes
+}
+
+// newUrlRefresher returns nil if fn is nil.
+func newUrlRefresher(fn RefreshFunc, base string) *urlRefresher {
+	if fn == nil {
+		return nil
+	}
+	return &urlRefresher{fn: fn, original: base, base: base}
+}
+
+// resolve returns the current URL for the original one and the generation
+// of the base URL, to be passed to refresh.
+func (r *urlRefresher) resolve(u string) (string, int) {
+	if r == nil {
+		return u, 0
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.gen != 0 && strings.HasPrefix(u, r.original) {
+		u = r.base + u[len(r.original):]
+	}
+	return u, r.gen
+}
+
+// refresh fetches the new base URL, unless it was refreshed since gen.
+func (r *urlRefresher) refresh(ctx context.Context, gen int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.gen != gen {
+		return nil
+	}
+	base, err := r.fn(ctx)
+	if err != nil {
+		return err
+	}
+	r.base = base
+	r.gen++
+	return nil
+}
+
+// ProgressiveRefresh returns a RefreshFunc for ReaderOptions, it fetches
+// the player config again and returns the new URL of the format.
+func (v *Video) ProgressiveRefresh(f *ProgressiveFormat) RefreshFunc {
+	return func(ctx context.Context) (string, error) {
+		formats, err := v.refreshFormats(ctx)
+		if err != nil {
+			return "", err
+		}
+		for _, format := range formats.Progressive {
+			if format.Profile == f.Profile && format.Height == f.Height {
+				return format.URL, nil
+			}
+		}
+		return "", ErrNoFormats
+	}
+}
+
+// DashRefresh returns a RefreshFunc for ReaderOptions, it fetches
+// the player config and the DASH JSON again and returns the new URL
+// of the stream.
+func (v *Video) DashRefresh(s *DashStream) RefreshFunc {
+	return func(ctx context.Context) (string, error) {
+		formats, err := v.refreshFormats(ctx)
+		if err != nil {
+			return "", err
+		}
+		if formats.Dash == nil {
+			return "", ErrNoFormats
+		}
+		streams, err := v.GetDashStreamsContext(ctx, formats.Dash.Url())
+		if err != nil {
+			return "", err
+		}
+		for _, stream := range streams.Video {
+			if stream.ID == s.ID {
+				return stream.URL, nil
+			}
+		}
+		for _, stream := range streams.Audio {
+			if stream.ID == s.ID {
+				return stream.URL, nil
+			}
+		}
+		return "", ErrNoFormats
+	}
+}
@@ -0,0 +1,142 @@
+package vimego
+
+import (
+	"io"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestFormatsExpires(t *testing.T) {
+	fake := newFakeVimeo(t)
+
+	formats, err := fake.video().Formats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, expires := range []time.Time{
+		formats.Progressive.Best().Expires(),
+		formats.Dash.Expires(),
+		formats.Hls.Expires(),
+	} {
+		if until := time.Until(expires); until < 59*time.Minute || until > 61*time.Minute {
+			t.Errorf("expires in %v", until)
+		}
+	}
+	if expires := (&ProgressiveFormat{URL: "https://example.com/video.mp4"}).Expires(); !expires.IsZero() {
+		t.Errorf("expires == %v", expires)
+	}
+}
+
+func TestProgressiveRefresh(t *testing.T) {
+	fake := newFakeVimeo(t)
+	video := fake.video()
+
+	formats, err := video.Formats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	format := formats.Progressive.Best()
+	fake.revoke()
+
+	reader, _, err := format.ReaderWithOptions(&ReaderOptions{
+		HTTPClient: video.HTTPClient,
+		Refresh:    video.ProgressiveRefresh(format),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reader.Close()
+
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "707341811.mp4 file" {
+		t.Errorf("data == %q", data)
+	}
+	if n := fake.count("/video/206152466/config"); n != 2 {
+		t.Errorf("the config was requested %d times", n)
+	}
+}
+
+func TestDashRefresh(t *testing.T) {
+	fake := newFakeVimeo(t)
+	video := fake.video()
+	video.Cache = NewMemoryCache(0)
+
+	formats, err := video.Formats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	streams, err := video.GetDashStreams(formats.Dash.Url())
+	if err != nil {
+		t.Fatal(err)
+	}
+	stream := &streams.Video.Best().DashStream
+	fake.revoke()
+
+	reader, _, err := stream.ReaderWithOptions(&ReaderOptions{
+		HTTPClient:  video.HTTPClient,
+		Concurrency: 2,
+		Refresh:     video.DashRefresh(stream),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reader.Close()
+
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "init 1080p8f03e7d2 segment 18f03e7d2 segment 2" {
+		t.Errorf("data == %q", data)
+	}
+
+	// the refreshed config replaced the cached one
+	refreshed, err := video.Formats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !refreshed.Dash.Expires().After(formats.Dash.Expires()) {
+		t.Error("the cached config wasn't replaced")
+	}
+	if n := fake.count("/video/206152466/config"); n != 2 {
+		t.Errorf("the config was requested %d times", n)
+	}
+}
+
+func TestDashRefreshRejected(t *testing.T) {
+	fake := newFakeVimeo(t)
+	fake.brokenSegments["segment-1.m4s"] = http.StatusForbidden
+	video := fake.video()
+
+	formats, err := video.Formats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	streams, err := video.GetDashStreams(formats.Dash.Url())
+	if err != nil {
+		t.Fatal(err)
+	}
+	stream := &streams.Audio.Best().DashStream
+
+	reader, _, err := stream.ReaderWithOptions(&ReaderOptions{
+		HTTPClient: video.HTTPClient,
+		Refresh:    video.DashRefresh(stream),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reader.Close()
+
+	// the URL is refreshed once
+	_, err = io.ReadAll(reader)
+	if err != ErrUnexpectedStatusCode(http.StatusForbidden) {
+		t.Errorf("err == %v", err)
+	}
+	if n := fake.count("/video/206152466/config"); n != 2 {
+		t.Errorf("the config was requested %d times", n)
+	}
+}
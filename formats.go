@@ -1,5 +1,13 @@
 package vimego
 
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
 type VideoFormats struct {
 	Progressive ProgressiveFormats `json:"progressive"`
 	Dash        *DashFormat        `json:"dash"`
@@ -46,6 +54,185 @@ type ProgressiveFormat struct {
 	Quality string `json:"quality"`
 	Origin  string `json:"origin"`
 	Height  int    `json:"height"`
+
+	// httpClient is the client of the video, used if the options have none.
+	httpClient *http.Client
+}
+
+// Expires returns when the signed URL of the format expires, zero if unknown.
+func (f *ProgressiveFormat) Expires() time.Time {
+	return urlExpiry([]byte(f.URL))
+}
+
+// Reader returns an io.ReadCloser for reading streaming data.
+// If httpClient is nil, the client of the video is used.
+func (f *ProgressiveFormat) Reader(httpClient *http.Client) (io.ReadCloser, int64, error) {
+	return f.ReaderWithOptions(&ReaderOptions{HTTPClient: httpClient})
+}
+
+// ReaderWithOptions returns an io.ReadCloser for reading streaming data.
+// If the connection breaks, the download continues from the same position.
+// The length is -1 if the server doesn't report it.
+// Concurrency, MaxBufferSize and StartTime aren't used.
+func (f *ProgressiveFormat) ReaderWithOptions(opts *ReaderOptions) (io.ReadCloser, int64, error) {
+	return f.ReaderContext(context.Background(), opts)
+}
+
+// ReaderContext is like ReaderWithOptions, cancelling ctx stops the download.
+func (f *ProgressiveFormat) ReaderContext(ctx context.Context, opts *ReaderOptions) (io.ReadCloser, int64, error) {
+	opts = opts.withClient(f.httpClient).withDefaults()
+	ctx, cancel := context.WithCancel(ctx)
+	r := &progressiveReader{
+		ctx:       ctx,
+		cancel:    cancel,
+		opts:      opts,
+		url:       f.URL,
+		refresher: newUrlRefresher(opts.Refresh, f.URL),
+		offset:    opts.Offset,
+		end:       -1,
+	}
+
+	err := r.openWithRetries()
+	if err != nil {
+		cancel()
+		return nil, 0, err
+	}
+
+	length := int64(-1)
+	if r.end >= 0 {
+		length = r.end - r.offset
+	}
+	r.progress = newProgressTracker(opts.Progress, r.offset, length, 0)
+	return r, length, nil
+}
+
+type progressiveReader struct {
+	ctx      context.Context
+	cancel   context.CancelFunc
+	opts     *ReaderOptions
+	url      string
+	offset   int64
+	end      int64 // the size of the whole file, -1 if unknown
+	body     io.ReadCloser
+	attempts int // failed attempts in a row
+	progress *progressTracker
+
+	refresher *urlRefresher
+	refreshed bool // whether the URL was refreshed since the last response
+}
+
+func (r *progressiveReader) Read(p []byte) (int, error) {
+	for {
+		if r.body == nil {
+			if err := r.openWithRetries(); err != nil {
+				return 0, err
+			}
+		}
+
+		n, err := r.body.Read(p)
+		r.offset += int64(n)
+		r.progress.add(n, 0)
+		if n != 0 {
+			r.attempts = 0
+		}
+
+		switch {
+		case err == io.EOF && (r.end < 0 || r.offset >= r.end):
+			r.progress.finish()
+			return n, io.EOF
+		case err == io.EOF:
+			err = io.ErrUnexpectedEOF
+		case err == nil:
+			return n, nil
+		}
+
+		// the connection is broken, reopen it on the next read
+		r.body.Close()
+		r.body = nil
+		if !isRetryable(r.ctx, err) || r.attempts >= r.opts.Retries {
+			return n, err
+		}
+		if n != 0 {
+			return n, nil
+		}
+		if err := sleepContext(r.ctx, r.opts.retryDelay(r.attempts, err)); err != nil {
+			return 0, err
+		}
+		r.attempts++
+	}
+}
+
+func (r *progressiveReader) Close() error {
+	r.cancel()
+	if r.body != nil {
+		return r.body.Close()
+	}
+	return nil
+}
+
+func (r *progressiveReader) openWithRetries() error {
+	for {
+		url, gen := r.refresher.resolve(r.url)
+		err := r.open(url)
+		if err == nil {
+			r.refreshed = false
+			return nil
+		}
+		if r.refresher != nil && !r.refreshed && isRejectedUrl(err) {
+			// the URL expired, continue from the new one
+			if err := r.refresher.refresh(r.ctx, gen); err != nil {
+				return err
+			}
+			r.refreshed = true
+			continue
+		}
+		if r.attempts >= r.opts.Retries || !isRetryable(r.ctx, err) {
+			return unwrapRetryAfter(err)
+		}
+		if err := sleepContext(r.ctx, r.opts.retryDelay(r.attempts, err)); err != nil {
+			return err
+		}
+		r.attempts++
+	}
+}
+
+// open requests the file from the current offset.
+func (r *progressiveReader) open(url string) error {
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(r.ctx)
+	if r.offset != 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", r.offset))
+	}
+
+	resp, err := r.opts.HTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	if err := checkResponse(resp); err != nil {
+		resp.Body.Close()
+		return err
+	}
+
+	if resp.StatusCode != http.StatusPartialContent && r.offset != 0 {
+		// the server ignored the range, skip the data we already have
+		_, err = io.CopyN(io.Discard, resp.Body, r.offset)
+		if err != nil {
+			resp.Body.Close()
+			return err
+		}
+	}
+	if r.end < 0 && resp.ContentLength >= 0 {
+		r.end = resp.ContentLength
+		if resp.StatusCode == http.StatusPartialContent {
+			r.end += r.offset
+		}
+	}
+
+	r.body = resp.Body
+	return nil
 }
 
 type DashFormat struct {
@@ -84,6 +271,11 @@ func (s *DashFormat) Url() string {
 	return ""
 }
 
+// Expires returns when the signed URL of the format expires, zero if unknown.
+func (s *DashFormat) Expires() time.Time {
+	return urlExpiry([]byte(s.Url()))
+}
+
 type HlsFormat struct {
 	SeparateAv bool   `json:"separate_av"`
 	DefaultCdn string `json:"default_cdn"`
@@ -119,3 +311,8 @@ func (s *HlsFormat) Url() string {
 	}
 	return ""
 }
+
+// Expires returns when the signed URL of the format expires, zero if unknown.
+func (s *HlsFormat) Expires() time.Time {
+	return urlExpiry([]byte(s.Url()))
+}
@@ -0,0 +1,684 @@
+package vimego
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+type HlsStreams struct {
+	Video     HlsVideoStreams
+	Audio     HlsAudioStreams
+	Subtitles HlsSubtitleStreams
+}
+
+type HlsVideoStreams []*HlsVideoStream
+
+func (h HlsVideoStreams) Len() int {
+	return len(h)
+}
+
+func (h HlsVideoStreams) Less(a, b int) bool {
+	return h[a].Bandwidth < h[b].Bandwidth
+}
+
+func (h HlsVideoStreams) Swap(a, b int) {
+	h[a], h[b] = h[b], h[a]
+}
+
+// Best returns the HlsVideoStream with the highest bandwidth.
+func (h HlsVideoStreams) Best() *HlsVideoStream {
+	if len(h) != 0 {
+		return h[len(h)-1]
+	}
+	return nil
+}
+
+// Worst returns the HlsVideoStream with the lowest bandwidth.
+func (h HlsVideoStreams) Worst() *HlsVideoStream {
+	if len(h) != 0 {
+		return h[0]
+	}
+	return nil
+}
+
+type HlsAudioStreams []*HlsAudioStream
+
+func (h HlsAudioStreams) Len() int {
+	return len(h)
+}
+
+func (h HlsAudioStreams) Less(a, b int) bool {
+	if h[a].rank != h[b].rank {
+		return h[a].rank < h[b].rank
+	}
+	return h[a].Channels < h[b].Channels
+}
+
+func (h HlsAudioStreams) Swap(a, b int) {
+	h[a], h[b] = h[b], h[a]
+}
+
+// Best returns the HlsAudioStream used by the highest quality variants.
+func (h HlsAudioStreams) Best() *HlsAudioStream {
+	if len(h) != 0 {
+		return h[len(h)-1]
+	}
+	return nil
+}
+
+// Worst returns the HlsAudioStream used by the lowest quality variants.
+func (h HlsAudioStreams) Worst() *HlsAudioStream {
+	if len(h) != 0 {
+		return h[0]
+	}
+	return nil
+}
+
+type HlsSubtitleStreams []*HlsSubtitleStream
+
+// HlsStream is a media playlist referenced by the master playlist.
+type HlsStream struct {
+	URL string
+
+	// httpClient is the client of the video, used if none is given.
+	httpClient *http.Client
+}
+
+type HlsVideoStream struct {
+	Bandwidth        int
+	AverageBandwidth int
+	Width            int
+	Height           int
+	Codecs           string
+	FrameRate        float64
+	AudioGroup       string
+	SubtitlesGroup   string
+	HlsStream
+}
+
+type HlsAudioStream struct {
+	GroupID  string
+	Name     string
+	Language string
+	Default  bool
+	Channels int
+	HlsStream
+
+	// the highest bandwidth of the variants using the group
+	rank int
+}
+
+type HlsSubtitleStream struct {
+	GroupID  string
+	Name     string
+	Language string
+	Default  bool
+	Forced   bool
+	HlsStream
+}
+
+// GetHlsStreams returns HLS streams of the video.
+func (v *Video) GetHlsStreams(hlsUrl string) (*HlsStreams, error) {
+	return v.GetHlsStreamsContext(context.Background(), hlsUrl)
+}
+
+// GetHlsStreamsContext is like GetHlsStreams but uses ctx for the request.
+func (v *Video) GetHlsStreamsContext(ctx context.Context, hlsUrl string) (*HlsStreams, error) {
+	body, status, err := v.get(ctx, v.withHash(hlsUrl))
+	if err != nil {
+		return nil, err
+	}
+	if status >= 400 {
+		return nil, ErrUnexpectedStatusCode(status)
+	}
+
+	baseurl, err := url.Parse(hlsUrl)
+	if err != nil {
+		return nil, err
+	}
+	streams, err := parseHlsMaster(bytes.NewReader(body), baseurl)
+	if err != nil {
+		return nil, err
+	}
+	for _, stream := range streams.Video {
+		stream.httpClient = v.HTTPClient
+	}
+	for _, stream := range streams.Audio {
+		stream.httpClient = v.HTTPClient
+	}
+	for _, stream := range streams.Subtitles {
+		stream.httpClient = v.HTTPClient
+	}
+	return streams, nil
+}
+
+type HlsPlaylist struct {
+	TargetDuration int
+	MediaSequence  int
+	Map            *HlsSegment
+	Segments       []*HlsSegment
+
+	// httpClient is the client of the video, used if none is given.
+	httpClient *http.Client
+}
+
+// Length returns the total size of the playlist in bytes.
+// It returns -1 if the size can't be known without downloading it,
+// i.e. some segments have no byte range or are encrypted.
+func (p *HlsPlaylist) Length() int64 {
+	segments := p.Segments
+	if p.Map != nil {
+		segments = append([]*HlsSegment{p.Map}, segments...)
+	}
+
+	var length int64
+	for _, segment := range segments {
+		if segment.Length == 0 || segment.Key != nil {
+			return -1
+		}
+		length += segment.Length
+	}
+	return length
+}
+
+type HlsSegment struct {
+	URL      string
+	Duration float64
+	Sequence int
+	// Offset and Length describe the byte range of the segment.
+	// Length is 0 if the whole resource is the segment.
+	Offset int64
+	Length int64
+	Key    *HlsKey
+}
+
+type HlsKey struct {
+	Method string
+	URL    string
+	IV     []byte
+}
+
+// iv returns the initialization vector for the segment.
+func (s *HlsSegment) iv() []byte {
+	if s.Key.IV != nil {
+		return s.Key.IV
+	}
+	iv := make([]byte, aes.BlockSize)
+	binary.BigEndian.PutUint64(iv[8:], uint64(s.Sequence))
+	return iv
+}
+
+// Playlist returns the media playlist of the stream.
+// If httpClient is nil, the client of the video is used.
+func (s *HlsStream) Playlist(httpClient *http.Client) (*HlsPlaylist, error) {
+	return s.PlaylistContext(context.Background(), httpClient)
+}
+
+// PlaylistContext is like Playlist but uses ctx for the request.
+func (s *HlsStream) PlaylistContext(ctx context.Context, httpClient *http.Client) (*HlsPlaylist, error) {
+	if httpClient == nil {
+		httpClient = s.httpClient
+	}
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	req, _ := http.NewRequest("GET", s.URL, nil)
+	resp, err := httpClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		return nil, ErrUnexpectedStatusCode(resp.StatusCode)
+	}
+
+	baseurl, err := url.Parse(s.URL)
+	if err != nil {
+		return nil, err
+	}
+	playlist, err := parseHlsMedia(resp.Body, baseurl)
+	if err != nil {
+		return nil, err
+	}
+	playlist.httpClient = s.httpClient
+	return playlist, nil
+}
+
+// Reader returns an io.ReadCloser for reading streaming data.
+// The length is -1 if the playlist doesn't specify byte ranges.
+func (s *HlsStream) Reader(httpClient *http.Client) (io.ReadCloser, int64, error) {
+	return s.ReaderWithOptions(&ReaderOptions{HTTPClient: httpClient})
+}
+
+// ReaderWithOptions returns an io.ReadCloser for reading streaming data.
+// The length is -1 if the playlist doesn't specify byte ranges.
+func (s *HlsStream) ReaderWithOptions(opts *ReaderOptions) (io.ReadCloser, int64, error) {
+	return s.ReaderContext(context.Background(), opts)
+}
+
+// ReaderContext is like ReaderWithOptions, cancelling ctx stops the download.
+func (s *HlsStream) ReaderContext(ctx context.Context, opts *ReaderOptions) (io.ReadCloser, int64, error) {
+	opts = opts.withClient(s.httpClient)
+	playlist, err := s.PlaylistContext(ctx, opts.withDefaults().HTTPClient)
+	if err != nil {
+		return nil, 0, err
+	}
+	return playlist.ReaderContext(ctx, opts)
+}
+
+// Reader returns an io.ReadCloser for reading streaming data.
+// The length is -1 if the playlist doesn't specify byte ranges.
+func (p *HlsPlaylist) Reader(httpClient *http.Client) (io.ReadCloser, int64, error) {
+	return p.ReaderWithOptions(&ReaderOptions{HTTPClient: httpClient})
+}
+
+// ReaderWithOptions returns an io.ReadCloser for reading streaming data.
+// The length is -1 if the playlist doesn't specify byte ranges,
+// in this case Offset isn't supported.
+func (p *HlsPlaylist) ReaderWithOptions(opts *ReaderOptions) (io.ReadCloser, int64, error) {
+	return p.ReaderContext(context.Background(), opts)
+}
+
+// ReaderContext is like ReaderWithOptions, cancelling ctx stops the download.
+func (p *HlsPlaylist) ReaderContext(ctx context.Context, opts *ReaderOptions) (io.ReadCloser, int64, error) {
+	opts = opts.withClient(p.httpClient)
+	httpClient := opts.withDefaults().HTTPClient
+
+	var keysMu sync.Mutex
+	keys := map[string][]byte{}
+
+	loadKey := func(ctx context.Context, keyUrl string) ([]byte, error) {
+		keysMu.Lock()
+		defer keysMu.Unlock()
+
+		if key, ok := keys[keyUrl]; ok {
+			return key, nil
+		}
+		req, _ := http.NewRequest("GET", keyUrl, nil)
+		resp, err := httpClient.Do(req.WithContext(ctx))
+		if err != nil {
+			return nil, err
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode >= 400 {
+			return nil, ErrUnexpectedStatusCode(resp.StatusCode)
+		}
+
+		key, err := io.ReadAll(io.LimitReader(resp.Body, aes.BlockSize+1))
+		if err != nil {
+			return nil, err
+		}
+		if len(key) != aes.BlockSize {
+			return nil, fmt.Errorf("invalid key length: %d", len(key))
+		}
+		keys[keyUrl] = key
+		return key, nil
+	}
+
+	newSegment := func(s *HlsSegment) *segment {
+		seg := &segment{
+			url:    s.URL,
+			offset: s.Offset,
+			length: s.Length,
+			size:   s.Length,
+		}
+		if s.Key != nil {
+			keyUrl, iv := s.Key.URL, s.iv()
+			seg.decode = func(ctx context.Context, data []byte) ([]byte, error) {
+				key, err := loadKey(ctx, keyUrl)
+				if err != nil {
+					return nil, err
+				}
+				return decryptHlsSegment(data, key, iv)
+			}
+		}
+		return seg
+	}
+
+	var startTime, position float64
+	var offset int64
+	if opts != nil {
+		startTime, offset = opts.StartTime, opts.Offset
+	}
+
+	segments := make([]*segment, 0, len(p.Segments)+1)
+	if p.Map != nil {
+		segments = append(segments, newSegment(p.Map))
+	}
+	for _, s := range p.Segments {
+		position += s.Duration
+		if startTime > 0 && position <= startTime {
+			continue
+		}
+		segments = append(segments, newSegment(s))
+	}
+
+	segments, err := skipBytes(segments, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	return readSegments(ctx, opts, segments, nil), segmentsLength(segments), nil
+}
+
+// decryptHlsSegment decrypts an AES-128 segment and removes its PKCS#7 padding.
+func decryptHlsSegment(data, key, iv []byte) ([]byte, error) {
+	if len(data) == 0 || len(data)%aes.BlockSize != 0 {
+		return nil, fmt.Errorf("invalid encrypted segment length: %d", len(data))
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	cipher.NewCBCDecrypter(block, iv).CryptBlocks(data, data)
+
+	padding := int(data[len(data)-1])
+	if padding == 0 || padding > aes.BlockSize {
+		return nil, fmt.Errorf("invalid segment padding: %d", padding)
+	}
+	return data[:len(data)-padding], nil
+}
+
+func parseHlsMedia(r io.Reader, baseurl *url.URL) (*HlsPlaylist, error) {
+	lines, err := readHlsLines(r)
+	if err != nil {
+		return nil, err
+	}
+
+	result := HlsPlaylist{}
+	var key *HlsKey
+	var segment *HlsSegment
+	// the end of the previous byte range, used when the offset is omitted
+	var nextOffset int64
+
+	for _, line := range lines {
+		tag, value := splitHlsTag(line)
+		switch {
+		case tag == "#EXT-X-STREAM-INF":
+			return nil, fmt.Errorf("%w: expected a media playlist", ErrInvalidPlaylist)
+		case tag == "#EXT-X-TARGETDURATION":
+			result.TargetDuration, _ = strconv.Atoi(value)
+		case tag == "#EXT-X-MEDIA-SEQUENCE":
+			result.MediaSequence, _ = strconv.Atoi(value)
+		case tag == "#EXT-X-KEY":
+			attrs := parseHlsAttributes(value)
+			switch attrs["METHOD"] {
+			case "NONE":
+				key = nil
+			case "AES-128":
+				key = &HlsKey{Method: attrs["METHOD"]}
+				key.URL, err = resolveHlsUrl(baseurl, attrs["URI"])
+				if err != nil {
+					return nil, err
+				}
+				if iv, ok := attrs["IV"]; ok {
+					key.IV, err = hex.DecodeString(
+						strings.TrimPrefix(strings.TrimPrefix(iv, "0x"), "0X"),
+					)
+					if err != nil || len(key.IV) != aes.BlockSize {
+						return nil, fmt.Errorf("%w: invalid IV %q", ErrInvalidPlaylist, iv)
+					}
+				}
+			default:
+				return nil, fmt.Errorf(
+					"%w: unsupported encryption method %q", ErrInvalidPlaylist, attrs["METHOD"],
+				)
+			}
+		case tag == "#EXT-X-MAP":
+			attrs := parseHlsAttributes(value)
+			result.Map = &HlsSegment{}
+			result.Map.URL, err = resolveHlsUrl(baseurl, attrs["URI"])
+			if err != nil {
+				return nil, err
+			}
+			if byterange, ok := attrs["BYTERANGE"]; ok {
+				result.Map.Length, result.Map.Offset, err = parseHlsByteRange(byterange, 0)
+				if err != nil {
+					return nil, err
+				}
+			}
+			// the init section is encrypted with the current key,
+			// which needs an IV as it has no sequence number
+			if key != nil && key.IV == nil {
+				return nil, fmt.Errorf("%w: no IV for the encrypted init section", ErrInvalidPlaylist)
+			}
+			result.Map.Key = key
+		case tag == "#EXTINF":
+			if segment == nil {
+				segment = &HlsSegment{}
+			}
+			duration := strings.SplitN(value, ",", 2)[0]
+			segment.Duration, _ = strconv.ParseFloat(duration, 64)
+		case tag == "#EXT-X-BYTERANGE":
+			if segment == nil {
+				segment = &HlsSegment{}
+			}
+			segment.Length, segment.Offset, err = parseHlsByteRange(value, nextOffset)
+			if err != nil {
+				return nil, err
+			}
+		case tag != "" || line == "":
+			// unsupported tag or comment
+		case segment != nil:
+			segment.URL, err = resolveHlsUrl(baseurl, line)
+			if err != nil {
+				return nil, err
+			}
+			segment.Sequence = result.MediaSequence + len(result.Segments)
+			segment.Key = key
+			if segment.Length != 0 {
+				nextOffset = segment.Offset + segment.Length
+			}
+			result.Segments = append(result.Segments, segment)
+			segment = nil
+		}
+	}
+
+	return &result, nil
+}
+
+// parseHlsByteRange parses a byte range like 1000@200.
+func parseHlsByteRange(s string, offset int64) (int64, int64, error) {
+	parts := strings.SplitN(s, "@", 2)
+	length, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return 0, 0, fmt.Errorf("%w: invalid byte range %q", ErrInvalidPlaylist, s)
+	}
+	if len(parts) == 2 {
+		offset, err = strconv.ParseInt(parts[1], 10, 64)
+		if err != nil {
+			return 0, 0, fmt.Errorf("%w: invalid byte range %q", ErrInvalidPlaylist, s)
+		}
+	}
+	return length, offset, nil
+}
+
+func parseHlsMaster(r io.Reader, baseurl *url.URL) (*HlsStreams, error) {
+	lines, err := readHlsLines(r)
+	if err != nil {
+		return nil, err
+	}
+
+	result := HlsStreams{}
+	var variant *HlsVideoStream
+	for _, line := range lines {
+		tag, value := splitHlsTag(line)
+		switch {
+		case tag == "#EXT-X-STREAM-INF":
+			attrs := parseHlsAttributes(value)
+			variant = &HlsVideoStream{
+				Codecs:         attrs["CODECS"],
+				AudioGroup:     attrs["AUDIO"],
+				SubtitlesGroup: attrs["SUBTITLES"],
+			}
+			variant.Bandwidth, _ = strconv.Atoi(attrs["BANDWIDTH"])
+			variant.AverageBandwidth, _ = strconv.Atoi(attrs["AVERAGE-BANDWIDTH"])
+			variant.FrameRate, _ = strconv.ParseFloat(attrs["FRAME-RATE"], 64)
+			if w, h, ok := parseHlsResolution(attrs["RESOLUTION"]); ok {
+				variant.Width, variant.Height = w, h
+			}
+		case tag == "#EXT-X-MEDIA":
+			attrs := parseHlsAttributes(value)
+			uri, ok := attrs["URI"]
+			if !ok {
+				// the rendition is muxed into the variant streams
+				continue
+			}
+			uri, err := resolveHlsUrl(baseurl, uri)
+			if err != nil {
+				return nil, err
+			}
+			switch attrs["TYPE"] {
+			case "AUDIO":
+				stream := &HlsAudioStream{
+					GroupID:   attrs["GROUP-ID"],
+					Name:      attrs["NAME"],
+					Language:  attrs["LANGUAGE"],
+					Default:   attrs["DEFAULT"] == "YES",
+					HlsStream: HlsStream{URL: uri},
+				}
+				// CHANNELS may look like "6/JOC", only the count matters
+				channels := strings.SplitN(attrs["CHANNELS"], "/", 2)[0]
+				stream.Channels, _ = strconv.Atoi(channels)
+				result.Audio = append(result.Audio, stream)
+			case "SUBTITLES":
+				result.Subtitles = append(result.Subtitles, &HlsSubtitleStream{
+					GroupID:   attrs["GROUP-ID"],
+					Name:      attrs["NAME"],
+					Language:  attrs["LANGUAGE"],
+					Default:   attrs["DEFAULT"] == "YES",
+					Forced:    attrs["FORCED"] == "YES",
+					HlsStream: HlsStream{URL: uri},
+				})
+			}
+		case tag != "" || line == "":
+			// unsupported tag or comment
+		case variant != nil:
+			uri, err := resolveHlsUrl(baseurl, line)
+			if err != nil {
+				return nil, err
+			}
+			variant.URL = uri
+			result.Video = append(result.Video, variant)
+			variant = nil
+		}
+	}
+
+	for _, audio := range result.Audio {
+		for _, video := range result.Video {
+			if video.AudioGroup == audio.GroupID && video.Bandwidth > audio.rank {
+				audio.rank = video.Bandwidth
+			}
+		}
+	}
+
+	sort.Stable(result.Video)
+	sort.Stable(result.Audio)
+
+	return &result, nil
+}
+
+// readHlsLines reads the playlist and returns its trimmed lines.
+func readHlsLines(r io.Reader) ([]string, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	var lines []string
+	for scanner.Scan() {
+		lines = append(lines, strings.TrimSpace(scanner.Text()))
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(lines) == 0 || !strings.HasPrefix(lines[0], "#EXTM3U") {
+		return nil, ErrInvalidPlaylist
+	}
+	return lines, nil
+}
+
+// splitHlsTag splits a tag line into its name and value.
+// It returns an empty tag for URI lines. Comments are returned
+// as unsupported tags, so they're never taken for URIs.
+func splitHlsTag(line string) (string, string) {
+	if !strings.HasPrefix(line, "#") {
+		return "", line
+	}
+	if i := strings.IndexByte(line, ':'); i != -1 {
+		return line[:i], line[i+1:]
+	}
+	return line, ""
+}
+
+// parseHlsAttributes parses an attribute list like
+// BANDWIDTH=1280000,CODECS="avc1.4d401f,mp4a.40.2".
+func parseHlsAttributes(s string) map[string]string {
+	attrs := map[string]string{}
+	for len(s) != 0 {
+		eq := strings.IndexByte(s, '=')
+		if eq == -1 {
+			break
+		}
+		key := strings.TrimSpace(s[:eq])
+		s = s[eq+1:]
+
+		var value string
+		if strings.HasPrefix(s, `"`) {
+			end := strings.IndexByte(s[1:], '"')
+			if end == -1 {
+				value, s = s[1:], ""
+			} else {
+				value, s = s[1:end+1], s[end+2:]
+			}
+			if i := strings.IndexByte(s, ','); i != -1 {
+				s = s[i+1:]
+			} else {
+				s = ""
+			}
+		} else if i := strings.IndexByte(s, ','); i != -1 {
+			value, s = s[:i], s[i+1:]
+		} else {
+			value, s = s, ""
+		}
+		attrs[key] = value
+	}
+	return attrs
+}
+
+func parseHlsResolution(s string) (int, int, bool) {
+	parts := strings.SplitN(s, "x", 2)
+	if len(parts) != 2 {
+		return 0, 0, false
+	}
+	w, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return 0, 0, false
+	}
+	h, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return 0, 0, false
+	}
+	return w, h, true
+}
+
+func resolveHlsUrl(baseurl *url.URL, ref string) (string, error) {
+	refurl, err := url.Parse(ref)
+	if err != nil {
+		return "", fmt.Errorf("couldn't parse playlist URI: %w", err)
+	}
+	return baseurl.ResolveReference(refurl).String(), nil
+}
@@ -0,0 +1,228 @@
+package vimego
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testHlsMaster = `#EXTM3U
+#EXT-X-INDEPENDENT-SEGMENTS
+#EXT-X-MEDIA:TYPE=AUDIO,GROUP-ID="audio-low",NAME="Original",AUTOSELECT=YES,DEFAULT=YES,CHANNELS="2",URI="audio/low/playlist.m3u8"
+#EXT-X-MEDIA:TYPE=AUDIO,GROUP-ID="audio-high",NAME="Original",AUTOSELECT=YES,DEFAULT=YES,CHANNELS="2",URI="audio/high/playlist.m3u8"
+#EXT-X-MEDIA:TYPE=SUBTITLES,GROUP-ID="subs",NAME="English",LANGUAGE="en",DEFAULT=NO,FORCED=NO,URI="/subs/en.m3u8"
+#EXT-X-STREAM-INF:BANDWIDTH=8000000,AVERAGE-BANDWIDTH=7000000,RESOLUTION=3840x2160,FRAME-RATE=25.000,CODECS="avc1.640033,mp4a.40.2",AUDIO="audio-high",SUBTITLES="subs"
+video/2160/playlist.m3u8
+#EXT-X-STREAM-INF:BANDWIDTH=400000,RESOLUTION=640x360,FRAME-RATE=25.000,CODECS="avc1.64001E,mp4a.40.2",AUDIO="audio-low"
+# the lowest variant
+video/360/playlist.m3u8
+`
+
+func TestParseHlsMaster(t *testing.T) {
+	baseurl, _ := url.Parse("https://cdn.example.com/hls/master.m3u8")
+	streams, err := parseHlsMaster(strings.NewReader(testHlsMaster), baseurl)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(streams.Video) != 2 {
+		t.Fatalf("len(streams.Video) == %d", len(streams.Video))
+	}
+	best := streams.Video.Best()
+	if best.Height != 2160 || best.Width != 3840 || best.FrameRate != 25 {
+		t.Errorf("unexpected best variant: %+v", best)
+	}
+	if best.Codecs != "avc1.640033,mp4a.40.2" {
+		t.Errorf("best.Codecs == %q", best.Codecs)
+	}
+	if best.URL != "https://cdn.example.com/hls/video/2160/playlist.m3u8" {
+		t.Errorf("best.URL == %q", best.URL)
+	}
+	if worst := streams.Video.Worst(); worst.Height != 360 ||
+		worst.URL != "https://cdn.example.com/hls/video/360/playlist.m3u8" {
+		t.Errorf("unexpected worst variant: %+v", worst)
+	}
+
+	if streams.Audio.Best().GroupID != "audio-high" {
+		t.Errorf("streams.Audio.Best().GroupID == %q", streams.Audio.Best().GroupID)
+	}
+	if streams.Audio.Best().Channels != 2 {
+		t.Error("streams.Audio.Best().Channels != 2")
+	}
+
+	if len(streams.Subtitles) != 1 {
+		t.Fatalf("len(streams.Subtitles) == %d", len(streams.Subtitles))
+	}
+	if streams.Subtitles[0].URL != "https://cdn.example.com/subs/en.m3u8" {
+		t.Errorf("streams.Subtitles[0].URL == %q", streams.Subtitles[0].URL)
+	}
+}
+
+func TestGetHlsStreams(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("User-Agent") != "vimego-test" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		io.WriteString(w, testHlsMaster)
+	}))
+	defer server.Close()
+
+	// a Video without a client uses the default one
+	video := &Video{Header: map[string][]string{"User-Agent": {"vimego-test"}}}
+	streams, err := video.GetHlsStreams(server.URL + "/master.m3u8")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(streams.Video) != 2 {
+		t.Errorf("len(streams.Video) == %d", len(streams.Video))
+	}
+	if len(video.Header) != 1 {
+		t.Errorf("video.Header == %v", video.Header)
+	}
+}
+
+func TestParseHlsMediaComments(t *testing.T) {
+	baseurl, _ := url.Parse("https://cdn.example.com/hls/media.m3u8")
+	playlist, err := parseHlsMedia(strings.NewReader(`#EXTM3U
+# generated by the packager
+#EXT-X-TARGETDURATION:6
+#EXTINF:6.000,
+# a comment
+first.m4s
+#EXTINF:6.000,
+#COMMENT: with a colon
+second.m4s
+`), baseurl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(playlist.Segments) != 2 {
+		t.Fatalf("len(playlist.Segments) == %d", len(playlist.Segments))
+	}
+	for i, name := range []string{"first.m4s", "second.m4s"} {
+		if url := playlist.Segments[i].URL; url != "https://cdn.example.com/hls/"+name {
+			t.Errorf("playlist.Segments[%d].URL == %q", i, url)
+		}
+	}
+}
+
+func TestHlsReader(t *testing.T) {
+	key := []byte("0123456789abcdef")
+	media := []byte("init|first segment|second segment")
+
+	encrypt := func(data []byte, sequence int) []byte {
+		padding := aes.BlockSize - len(data)%aes.BlockSize
+		data = append(append([]byte{}, data...), bytes.Repeat([]byte{byte(padding)}, padding)...)
+		iv := make([]byte, aes.BlockSize)
+		iv[len(iv)-1] = byte(sequence)
+		block, _ := aes.NewCipher(key)
+		cipher.NewCBCEncrypter(block, iv).CryptBlocks(data, data)
+		return data
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/media.m3u8", func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, `#EXTM3U
+#EXT-X-TARGETDURATION:6
+#EXT-X-MEDIA-SEQUENCE:7
+#EXT-X-MAP:URI="plain.mp4",BYTERANGE="5@0"
+#EXTINF:6.000,
+#EXT-X-BYTERANGE:13@5
+plain.mp4
+#EXT-X-KEY:METHOD=AES-128,URI="/key"
+#EXTINF:6.000,
+encrypted.m4s
+`)
+	})
+	mux.HandleFunc("/plain.mp4", func(w http.ResponseWriter, r *http.Request) {
+		http.ServeContent(w, r, "plain.mp4", time.Time{}, bytes.NewReader(media[:18]))
+	})
+	mux.HandleFunc("/encrypted.m4s", func(w http.ResponseWriter, r *http.Request) {
+		w.Write(encrypt(media[18:], 8))
+	})
+	mux.HandleFunc("/key", func(w http.ResponseWriter, r *http.Request) {
+		w.Write(key)
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	stream := &HlsStream{URL: server.URL + "/media.m3u8"}
+	reader, length, err := stream.Reader(server.Client())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reader.Close()
+
+	if length != -1 {
+		t.Errorf("length == %d, the playlist is encrypted", length)
+	}
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(data, media) {
+		t.Errorf("data == %q", data)
+	}
+}
+
+func TestHlsEncryptedMap(t *testing.T) {
+	key := []byte("0123456789abcdef")
+	iv := []byte("fedcba9876543210")
+	initSection := []byte("init section")
+
+	padding := aes.BlockSize - len(initSection)%aes.BlockSize
+	encrypted := append(append([]byte{}, initSection...), bytes.Repeat([]byte{byte(padding)}, padding)...)
+	block, _ := aes.NewCipher(key)
+	cipher.NewCBCEncrypter(block, iv).CryptBlocks(encrypted, encrypted)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/media.m3u8", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `#EXTM3U
+#EXT-X-TARGETDURATION:6
+#EXT-X-KEY:METHOD=AES-128,URI="/key",IV=0x%x
+#EXT-X-MAP:URI="init.mp4"
+`, iv)
+	})
+	mux.HandleFunc("/init.mp4", func(w http.ResponseWriter, r *http.Request) {
+		w.Write(encrypted)
+	})
+	mux.HandleFunc("/key", func(w http.ResponseWriter, r *http.Request) {
+		w.Write(key)
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	stream := &HlsStream{URL: server.URL + "/media.m3u8"}
+	reader, _, err := stream.Reader(server.Client())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reader.Close()
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(data, initSection) {
+		t.Errorf("data == %q", data)
+	}
+
+	// the IV is required
+	baseurl, _ := url.Parse(server.URL + "/media.m3u8")
+	_, err = parseHlsMedia(strings.NewReader(`#EXTM3U
+#EXT-X-KEY:METHOD=AES-128,URI="/key"
+#EXT-X-MAP:URI="init.mp4"
+`), baseurl)
+	if !errors.Is(err, ErrInvalidPlaylist) {
+		t.Errorf("err == %v", err)
+	}
+}
@@ -0,0 +1,231 @@
+package vimego
+
+import (
+	"io"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+const (
+	defaultBackoff    = time.Second
+	defaultMaxBackoff = time.Minute
+)
+
+// Limits limit the requests sent by a Client.
+type Limits struct {
+	// Rate is the number of requests per second, 0 means no limit.
+	Rate float64
+	// Burst is the number of requests that may be sent at once
+	// after a pause, 1 by default.
+	Burst int
+	// MaxConcurrent limits the number of requests in progress,
+	// including the reading of their bodies. 0 means no limit.
+	MaxConcurrent int
+	// Backoff is the pause after a 429 response without Retry-After,
+	// 1s by default. It doubles while the responses are 429.
+	Backoff time.Duration
+}
+
+// limiter is a token bucket with a semaphore. All its methods
+// are no-op for a nil limiter.
+type limiter struct {
+	limits Limits
+	sem    chan struct{}
+
+	mu          sync.Mutex
+	tokens      float64
+	last        time.Time
+	pausedUntil time.Time
+	backoff     time.Duration
+}
+
+// newLimiter returns nil if limits is nil.
+func newLimiter(limits *Limits) *limiter {
+	if limits == nil {
+		return nil
+	}
+	l := &limiter{limits: *limits, last: time.Now()}
+	if l.limits.Burst <= 0 {
+		l.limits.Burst = 1
+	}
+	if l.limits.Backoff <= 0 {
+		l.limits.Backoff = defaultBackoff
+	}
+	if l.limits.MaxConcurrent > 0 {
+		l.sem = make(chan struct{}, l.limits.MaxConcurrent)
+	}
+	l.tokens = float64(l.limits.Burst)
+	l.backoff = l.limits.Backoff
+	return l
+}
+
+// wait blocks until a request may be sent. If it succeeds,
+// release must be called once the request is finished.
+func (l *limiter) wait(req *http.Request) error {
+	if l == nil {
+		return nil
+	}
+	ctx := req.Context()
+	if l.sem != nil {
+		select {
+		case l.sem <- struct{}{}:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	for {
+		delay := l.reserve()
+		if delay <= 0 {
+			return nil
+		}
+		if err := sleepContext(ctx, delay); err != nil {
+			l.release()
+			return err
+		}
+	}
+}
+
+// reserve takes a token, it returns the time to wait if there is none.
+func (l *limiter) reserve() time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	if now.Before(l.pausedUntil) {
+		return l.pausedUntil.Sub(now)
+	}
+	if l.limits.Rate <= 0 {
+		return 0
+	}
+
+	l.tokens += now.Sub(l.last).Seconds() * l.limits.Rate
+	if l.tokens > float64(l.limits.Burst) {
+		l.tokens = float64(l.limits.Burst)
+	}
+	l.last = now
+	if l.tokens >= 1 {
+		l.tokens--
+		return 0
+	}
+	return time.Duration((1 - l.tokens) / l.limits.Rate * float64(time.Second))
+}
+
+func (l *limiter) release() {
+	if l == nil || l.sem == nil {
+		return
+	}
+	<-l.sem
+}
+
+// throttled pauses all the requests after a 429 response.
+func (l *limiter) throttled(retryAfter time.Duration) {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	delay := retryAfter
+	if delay <= 0 {
+		delay = l.backoff
+		l.backoff *= 2
+		if l.backoff > defaultMaxBackoff {
+			l.backoff = defaultMaxBackoff
+		}
+	}
+	if until := time.Now().Add(delay); until.After(l.pausedUntil) {
+		l.pausedUntil = until
+	}
+}
+
+// succeeded resets the backoff.
+func (l *limiter) succeeded() {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	l.backoff = l.limits.Backoff
+	l.mu.Unlock()
+}
+
+// limitedTransport applies the API limits to the requests to the endpoints
+// and the CDN limits to everything else.
+type limitedTransport struct {
+	base     http.RoundTripper
+	apiHosts map[string]bool
+	api      *limiter
+	cdn      *limiter
+}
+
+func newLimitedTransport(base http.RoundTripper, endpoints *Endpoints, api, cdn *Limits) *limitedTransport {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	t := &limitedTransport{
+		base:     base,
+		apiHosts: map[string]bool{},
+		api:      newLimiter(api),
+		cdn:      newLimiter(cdn),
+	}
+	for _, endpoint := range []string{
+		endpoints.Site, endpoints.Player, endpoints.V2, endpoints.API, endpoints.JWT,
+	} {
+		if u, err := url.Parse(endpoint); err == nil {
+			t.apiHosts[u.Host] = true
+		}
+	}
+	return t
+}
+
+func (t *limitedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	l := t.cdn
+	if t.apiHosts[req.URL.Host] {
+		l = t.api
+	}
+
+	// only the requests without a body can be repeated
+	retries := defaultRetries
+	if req.Body != nil && req.Body != http.NoBody {
+		retries = 0
+	}
+
+	for attempt := 0; ; attempt++ {
+		if err := l.wait(req); err != nil {
+			return nil, err
+		}
+		resp, err := t.base.RoundTrip(req)
+		if err != nil {
+			l.release()
+			return nil, err
+		}
+		if resp.StatusCode != http.StatusTooManyRequests {
+			l.succeeded()
+			resp.Body = &releasingBody{ReadCloser: resp.Body, release: l.release}
+			return resp, nil
+		}
+
+		l.throttled(parseRetryAfter(resp.Header.Get("Retry-After")))
+		if attempt >= retries || l == nil {
+			resp.Body = &releasingBody{ReadCloser: resp.Body, release: l.release}
+			return resp, nil
+		}
+		io.Copy(io.Discard, io.LimitReader(resp.Body, 4<<10))
+		resp.Body.Close()
+		l.release()
+	}
+}
+
+// releasingBody releases the limiter once the body is closed.
+type releasingBody struct {
+	io.ReadCloser
+	release func()
+	once    sync.Once
+}
+
+func (b *releasingBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(b.release)
+	return err
+}
@@ -0,0 +1,119 @@
+package vimego
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// newLimitedTestClient returns a Client sending all its requests to the server.
+func newLimitedTestClient(server *httptest.Server, api, cdn *Limits) *http.Client {
+	client := NewClient()
+	client.HTTPClient = newTestClient(server)
+	client.APILimits = api
+	client.CDNLimits = cdn
+	return client.httpClient()
+}
+
+func get(t *testing.T, client *http.Client, url string) int {
+	resp, err := client.Get(url)
+	if err != nil {
+		t.Error(err)
+		return 0
+	}
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	return resp.StatusCode
+}
+
+func TestLimitsRate(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	client := newLimitedTestClient(server, &Limits{Rate: 50, Burst: 2}, nil)
+	start := time.Now()
+	for i := 0; i < 7; i++ {
+		get(t, client, "https://player.vimeo.com/video/1/config")
+	}
+	// 2 requests are sent at once, the others every 20ms
+	if elapsed := time.Since(start); elapsed < 90*time.Millisecond {
+		t.Errorf("7 requests took %v", elapsed)
+	}
+
+	// the CDN isn't limited
+	start = time.Now()
+	for i := 0; i < 7; i++ {
+		get(t, client, "https://vod-adaptive.akamaized.net/segment.m4s")
+	}
+	if elapsed := time.Since(start); elapsed > 80*time.Millisecond {
+		t.Errorf("7 requests took %v", elapsed)
+	}
+}
+
+func TestLimitsConcurrency(t *testing.T) {
+	var mu sync.Mutex
+	var active, peak int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		active++
+		if active > peak {
+			peak = active
+		}
+		mu.Unlock()
+
+		time.Sleep(10 * time.Millisecond)
+		w.Write([]byte("data"))
+
+		mu.Lock()
+		active--
+		mu.Unlock()
+	}))
+	defer server.Close()
+
+	client := newLimitedTestClient(server, nil, &Limits{MaxConcurrent: 2})
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			get(t, client, "https://vod-adaptive.akamaized.net/segment.m4s")
+		}()
+	}
+	wg.Wait()
+
+	if peak != 2 {
+		t.Errorf("peak == %d", peak)
+	}
+}
+
+func TestLimitsTooManyRequests(t *testing.T) {
+	var mu sync.Mutex
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requests++
+		request := requests
+		mu.Unlock()
+
+		if request <= 2 {
+			w.WriteHeader(http.StatusTooManyRequests)
+		}
+	}))
+	defer server.Close()
+
+	client := newLimitedTestClient(server, &Limits{Backoff: 10 * time.Millisecond}, nil)
+	start := time.Now()
+	if code := get(t, client, "https://api.vimeo.com/search"); code != http.StatusOK {
+		t.Errorf("status code %d", code)
+	}
+	// the pauses are 10ms and 20ms
+	if elapsed := time.Since(start); elapsed < 30*time.Millisecond {
+		t.Errorf("the request took %v", elapsed)
+	}
+	if requests != 3 {
+		t.Errorf("requests == %d", requests)
+	}
+}
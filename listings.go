@@ -0,0 +1,171 @@
+package vimego
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+)
+
+// listing has the settings and the videos shared by User, Channel and Group.
+type listing struct {
+	// Name is the name in the URL, e.g. "crystalcastles",
+	// or the ID of the user, the channel or the group.
+	Name string
+	// PerPage is the number of videos per request, up to 100 (the default).
+	PerPage int
+
+	Header     map[string][]string
+	HTTPClient *http.Client
+	Endpoints  *Endpoints
+	// Cache is passed to the videos.
+	Cache Cache
+
+	// prefix is the API path of the kind of listing, e.g. "/users".
+	prefix  string
+	session apiSession
+}
+
+func (l *listing) api() *apiClient {
+	return &apiClient{
+		header:     l.Header,
+		httpClient: l.HTTPClient,
+		endpoints:  l.Endpoints,
+		cache:      l.Cache,
+		session:    &l.session,
+	}
+}
+
+func (l *listing) path() string {
+	return l.prefix + "/" + url.PathEscape(l.Name)
+}
+
+// info decodes the requested fields of the listing into result.
+func (l *listing) info(ctx context.Context, fields string, result interface{}) error {
+	params := url.Values{"fields": {fields}}
+	return l.api().get(ctx, l.path()+"?"+params.Encode(), result)
+}
+
+// Videos returns all the videos.
+func (l *listing) Videos() ([]*Video, error) {
+	return l.VideosContext(context.Background())
+}
+
+// VideosContext is like Videos but uses ctx for the requests.
+func (l *listing) VideosContext(ctx context.Context) ([]*Video, error) {
+	return allVideos(ctx, l.PageContext)
+}
+
+// Page returns the videos from the requested page, starting from 1.
+func (l *listing) Page(page int) (*VideoPage, error) {
+	return l.PageContext(context.Background(), page)
+}
+
+// PageContext is like Page but uses ctx for the requests.
+func (l *listing) PageContext(ctx context.Context, page int) (*VideoPage, error) {
+	return l.api().videoPage(ctx, l.path()+"/videos", page, l.PerPage, nil)
+}
+
+// User is a Vimeo user.
+type User struct {
+	listing
+}
+
+type UserInfo struct {
+	Name     string `json:"name"`
+	Link     string `json:"link"`
+	Location string `json:"location"`
+	Bio      string `json:"bio"`
+	Pictures struct {
+		Sizes []PictureSize `json:"sizes"`
+	} `json:"pictures"`
+	Metadata struct {
+		Connections struct {
+			Followers struct {
+				Total int `json:"total"`
+			} `json:"followers"`
+			Videos struct {
+				Total int `json:"total"`
+			} `json:"videos"`
+		} `json:"connections"`
+	} `json:"metadata"`
+}
+
+// Info returns the profile of the user.
+func (u *User) Info() (*UserInfo, error) {
+	return u.InfoContext(context.Background())
+}
+
+// InfoContext is like Info but uses ctx for the requests.
+func (u *User) InfoContext(ctx context.Context) (*UserInfo, error) {
+	var result UserInfo
+	err := u.info(ctx, "name,link,location,bio,pictures.sizes,"+
+		"metadata.connections.followers.total,metadata.connections.videos.total", &result)
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// Channel is a channel curating videos.
+type Channel struct {
+	listing
+}
+
+type ChannelInfo struct {
+	Name        string `json:"name"`
+	Link        string `json:"link"`
+	Description string `json:"description"`
+	Pictures    struct {
+		Sizes []PictureSize `json:"sizes"`
+	} `json:"pictures"`
+	Metadata struct {
+		Connections struct {
+			// Users are the followers of a channel
+			// or the members of a group.
+			Users struct {
+				Total int `json:"total"`
+			} `json:"users"`
+			Videos struct {
+				Total int `json:"total"`
+			} `json:"videos"`
+		} `json:"connections"`
+	} `json:"metadata"`
+}
+
+// Info returns the description of the channel.
+func (c *Channel) Info() (*ChannelInfo, error) {
+	return c.InfoContext(context.Background())
+}
+
+// InfoContext is like Info but uses ctx for the requests.
+func (c *Channel) InfoContext(ctx context.Context) (*ChannelInfo, error) {
+	return groupInfo(ctx, &c.listing)
+}
+
+// Group is a group of users sharing videos.
+type Group struct {
+	listing
+}
+
+type GroupInfo = ChannelInfo
+
+// Info returns the description of the group.
+func (g *Group) Info() (*GroupInfo, error) {
+	return g.InfoContext(context.Background())
+}
+
+// InfoContext is like Info but uses ctx for the requests.
+func (g *Group) InfoContext(ctx context.Context) (*GroupInfo, error) {
+	return groupInfo(ctx, &g.listing)
+}
+
+// groupInfo returns the info of a channel or a group.
+func groupInfo(ctx context.Context, l *listing) (*ChannelInfo, error) {
+	var result ChannelInfo
+	err := l.info(ctx, "name,link,description,pictures.sizes,"+
+		"metadata.connections.users.total,metadata.connections.videos.total", &result)
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
@@ -0,0 +1,170 @@
+package vimego
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestNewUser(t *testing.T) {
+	for _, url := range []string{
+		"crystalcastles",
+		"https://vimeo.com/crystalcastles",
+		"vimeo.com/crystalcastles/videos",
+	} {
+		user, err := NewUser(url)
+		if err != nil {
+			t.Errorf("NewUser(%q): %v", url, err)
+			continue
+		}
+		if user.Name != "crystalcastles" {
+			t.Errorf("NewUser(%q).Name == %q", url, user.Name)
+		}
+	}
+	for _, url := range []string{
+		"https://vimeo.com/206152466",
+		"https://vimeo.com/channels/staffpicks",
+		"https://example.com/crystalcastles",
+		"crystal castles",
+	} {
+		if _, err := NewUser(url); !errors.Is(err, ErrInvalidUrl) {
+			t.Errorf("NewUser(%q): err == %v", url, err)
+		}
+	}
+}
+
+func TestNewChannelAndGroup(t *testing.T) {
+	for _, url := range []string{
+		"staffpicks",
+		"https://vimeo.com/channels/staffpicks",
+		"https://vimeo.com/channels/staffpicks/206152466",
+	} {
+		channel, err := NewChannel(url)
+		if err != nil {
+			t.Errorf("NewChannel(%q): %v", url, err)
+		} else if channel.Name != "staffpicks" {
+			t.Errorf("NewChannel(%q).Name == %q", url, channel.Name)
+		}
+	}
+	if _, err := NewChannel("https://vimeo.com/groups/staffpicks"); !errors.Is(err, ErrInvalidUrl) {
+		t.Errorf("NewChannel: err == %v", err)
+	}
+
+	for _, url := range []string{
+		"musicvideos",
+		"https://vimeo.com/groups/musicvideos",
+		"https://vimeo.com/groups/musicvideos/videos/206152466",
+	} {
+		group, err := NewGroup(url)
+		if err != nil {
+			t.Errorf("NewGroup(%q): %v", url, err)
+		} else if group.Name != "musicvideos" {
+			t.Errorf("NewGroup(%q).Name == %q", url, group.Name)
+		}
+	}
+	if _, err := NewGroup("https://vimeo.com/musicvideos"); !errors.Is(err, ErrInvalidUrl) {
+		t.Errorf("NewGroup: err == %v", err)
+	}
+}
+
+func TestUserVideos(t *testing.T) {
+	fake := newFakeVimeo(t)
+	client := NewClient()
+	client.HTTPClient = fake.client()
+	user, err := client.NewUser("crystalcastles")
+	if err != nil {
+		t.Fatal(err)
+	}
+	user.PerPage = 2
+
+	info, err := user.Info()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Name != "Crystal Castles" || info.Metadata.Connections.Followers.Total != 1843 {
+		t.Errorf("info: %+v", info)
+	}
+
+	videos, err := user.Videos()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(videos) != 3 || videos[1].Hash != "3c5a1f9e0b" {
+		t.Fatalf("videos: %+v", videos)
+	}
+	if n := fake.count("/users/crystalcastles/videos"); n != 2 {
+		t.Errorf("%d pages were requested", n)
+	}
+
+	page, err := user.Page(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !page.Last || len(page.Videos) != 1 || page.Total != 3 {
+		t.Errorf("page: %+v", page)
+	}
+}
+
+func TestChannelAndGroupVideos(t *testing.T) {
+	fake := newFakeVimeo(t)
+	client := NewClient()
+	client.HTTPClient = fake.client()
+
+	channel, _ := client.NewChannel("staffpicks")
+	channelInfo, err := channel.Info()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if channelInfo.Metadata.Connections.Users.Total != 1923041 {
+		t.Errorf("channel info: %+v", channelInfo)
+	}
+	videos, err := channel.Videos()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(videos) != 3 {
+		t.Errorf("len(channel videos) == %d", len(videos))
+	}
+
+	group, _ := client.NewGroup("musicvideos")
+	groupInfo, err := group.Info()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if groupInfo.Name != "Music Videos" || groupInfo.Metadata.Connections.Videos.Total != 5 {
+		t.Errorf("group info: %+v", groupInfo)
+	}
+	// the videos are built from their URIs, the live event is skipped
+	videos, err = group.Videos()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(videos) != 4 {
+		t.Fatalf("len(group videos) == %d", len(videos))
+	}
+	if videos[3].VideoId != 206152469 || videos[3].Url != "https://vimeo.com/206152469" {
+		t.Errorf("the on demand video: %+v", videos[3])
+	}
+
+	missing, _ := client.NewGroup("nonexistent")
+	if _, err := missing.Videos(); !errors.Is(err, ErrUnexpectedStatusCode(404)) {
+		t.Errorf("missing group: err == %v", err)
+	}
+}
+
+func TestUserZeroValue(t *testing.T) {
+	fake := newFakeVimeo(t)
+	user := &User{listing{Name: "crystalcastles", HTTPClient: fake.client(), prefix: "/users"}}
+
+	videos, err := user.Videos()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(videos) != 3 {
+		t.Errorf("len(videos) == %d", len(videos))
+	}
+
+	// a bad request for the first page isn't the end of the list
+	if _, err := user.Page(0); err != ErrUnexpectedStatusCode(400) {
+		t.Errorf("err == %v", err)
+	}
+}
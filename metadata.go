@@ -1,6 +1,11 @@
 package vimego
 
-import "time"
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"time"
+)
 
 type Metadata struct {
 	ID                 int    `json:"id"`
@@ -32,3 +37,75 @@ type Metadata struct {
 func (m *Metadata) GetUploadDate() (time.Time, error) {
 	return time.Parse("2006-01-02 15:04:05", m.UploadDate)
 }
+
+var imageSizePattern = regexp.MustCompile(`_\d+x\d+$`)
+
+// configMetadata builds the metadata from the player config. The description,
+// upload date, stats and tags aren't in the config, they are left empty.
+func configMetadata(config []byte) (*Metadata, error) {
+	var configData struct {
+		Video *struct {
+			ID              int               `json:"id"`
+			Title           string            `json:"title"`
+			URL             string            `json:"url"`
+			ShareURL        string            `json:"share_url"`
+			Duration        int               `json:"duration"`
+			Width           int               `json:"width"`
+			Height          int               `json:"height"`
+			EmbedPermission string            `json:"embed_permission"`
+			Thumbs          map[string]string `json:"thumbs"`
+			Owner           struct {
+				ID   int    `json:"id"`
+				Name string `json:"name"`
+				URL  string `json:"url"`
+				Img  string `json:"img"`
+			} `json:"owner"`
+		} `json:"video"`
+	}
+	err := json.Unmarshal(config, &configData)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't decode config JSON: %w", err)
+	}
+	video := configData.Video
+	if video == nil || video.ID == 0 {
+		return nil, ErrParsingFailed
+	}
+
+	metadata := &Metadata{
+		ID:           video.ID,
+		Title:        video.Title,
+		URL:          video.URL,
+		UserID:       video.Owner.ID,
+		UserName:     video.Owner.Name,
+		UserURL:      video.Owner.URL,
+		Duration:     video.Duration,
+		Width:        video.Width,
+		Height:       video.Height,
+		EmbedPrivacy: video.EmbedPermission,
+	}
+	if metadata.URL == "" {
+		metadata.URL = video.ShareURL
+	}
+	// the API calls it "anywhere"
+	if metadata.EmbedPrivacy == "public" {
+		metadata.EmbedPrivacy = "anywhere"
+	}
+
+	// the images are resized by the CDN, the sizes are the ones of the API
+	if base := video.Thumbs["base"]; base != "" {
+		metadata.ThumbnailSmall = base + "_100x75"
+		metadata.ThumbnailMedium = base + "_200x150"
+		metadata.ThumbnailLarge = base + "_640"
+	}
+	if large := video.Thumbs["640"]; large != "" {
+		metadata.ThumbnailLarge = large
+	}
+	if img := video.Owner.Img; img != "" {
+		base := imageSizePattern.ReplaceAllString(img, "")
+		metadata.UserPortraitSmall = base + "_30x30"
+		metadata.UserPortraitMedium = base + "_75x75"
+		metadata.UserPortraitLarge = base + "_100x100"
+		metadata.UserPortraitHuge = base + "_300x300"
+	}
+	return metadata, nil
+}
@@ -0,0 +1,277 @@
+package vimego
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// containerBoxes are parsed recursively by parseBoxes.
+var containerBoxes = map[string]bool{
+	"moov": true,
+	"trak": true,
+	"mdia": true,
+	"minf": true,
+	"stbl": true,
+	"edts": true,
+	"mvex": true,
+	"moof": true,
+	"traf": true,
+}
+
+// box is a parsed MP4 box. Leaf boxes keep their payload in data,
+// containers keep their children.
+type box struct {
+	typ      string
+	data     []byte
+	children []*box
+}
+
+// child returns the first child with the given type.
+func (b *box) child(typ string) *box {
+	for _, c := range b.children {
+		if c.typ == typ {
+			return c
+		}
+	}
+	return nil
+}
+
+// path returns the first descendant found by the types.
+func (b *box) path(types ...string) *box {
+	for _, typ := range types {
+		if b = b.child(typ); b == nil {
+			return nil
+		}
+	}
+	return b
+}
+
+// replace replaces the first child with the given type or appends it.
+func (b *box) replace(c *box) {
+	for i, old := range b.children {
+		if old.typ == c.typ {
+			b.children[i] = c
+			return
+		}
+	}
+	b.children = append(b.children, c)
+}
+
+func (b *box) size() int64 {
+	size := int64(len(b.data))
+	for _, c := range b.children {
+		size += c.size()
+	}
+	if size+8 > 0xFFFFFFFF {
+		return size + 16
+	}
+	return size + 8
+}
+
+func (b *box) bytes() []byte {
+	buf := make([]byte, 0, b.size())
+	return b.append(buf)
+}
+
+func (b *box) append(buf []byte) []byte {
+	buf = appendBoxHeader(buf, b.typ, b.size())
+	buf = append(buf, b.data...)
+	for _, c := range b.children {
+		buf = c.append(buf)
+	}
+	return buf
+}
+
+func appendBoxHeader(buf []byte, typ string, size int64) []byte {
+	if size > 0xFFFFFFFF {
+		buf = appendUint32(buf, 1)
+		buf = append(buf, typ...)
+		return appendUint64(buf, uint64(size))
+	}
+	buf = appendUint32(buf, uint32(size))
+	return append(buf, typ...)
+}
+
+// fullBox returns a leaf box with version and flags.
+func fullBox(typ string, version byte, flags uint32, payload []byte) *box {
+	data := make([]byte, 4, 4+len(payload))
+	binary.BigEndian.PutUint32(data, flags&0xFFFFFF)
+	data[0] = version
+	return &box{typ: typ, data: append(data, payload...)}
+}
+
+// boxHeader parses the header of the box at the beginning of data.
+func boxHeader(data []byte) (typ string, headerSize int, size int64, err error) {
+	if len(data) < 8 {
+		return "", 0, 0, ErrInvalidMP4
+	}
+	size = int64(binary.BigEndian.Uint32(data))
+	typ = string(data[4:8])
+	headerSize = 8
+	switch size {
+	case 0:
+		size = int64(len(data))
+	case 1:
+		if len(data) < 16 {
+			return "", 0, 0, ErrInvalidMP4
+		}
+		size = int64(binary.BigEndian.Uint64(data[8:]))
+		headerSize = 16
+	}
+	if size < int64(headerSize) || size > int64(len(data)) {
+		return "", 0, 0, fmt.Errorf("%w: %q", ErrInvalidMP4, typ)
+	}
+	return typ, headerSize, size, nil
+}
+
+// parseBoxes parses all the boxes of data.
+func parseBoxes(data []byte) ([]*box, error) {
+	var boxes []*box
+	for len(data) != 0 {
+		typ, headerSize, size, err := boxHeader(data)
+		if err != nil {
+			return nil, err
+		}
+		b := &box{typ: typ}
+		payload := data[headerSize:size]
+		if containerBoxes[typ] {
+			b.children, err = parseBoxes(payload)
+			if err != nil {
+				return nil, err
+			}
+		} else {
+			b.data = payload
+		}
+		boxes = append(boxes, b)
+		data = data[size:]
+	}
+	return boxes, nil
+}
+
+// readBox reads a whole top-level box from r.
+func readBox(r io.Reader) (string, []byte, error) {
+	header := make([]byte, 8, 16)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return "", nil, err
+	}
+	size := int64(binary.BigEndian.Uint32(header))
+	typ := string(header[4:8])
+	switch size {
+	case 0:
+		// the box lasts until the end of the stream
+		rest, err := io.ReadAll(r)
+		if err != nil {
+			return "", nil, err
+		}
+		return typ, append(header, rest...), nil
+	case 1:
+		header = header[:16]
+		if _, err := io.ReadFull(r, header[8:]); err != nil {
+			return "", nil, unexpectedEOF(err)
+		}
+		size = int64(binary.BigEndian.Uint64(header[8:]))
+	}
+	if size < int64(len(header)) {
+		return "", nil, fmt.Errorf("%w: %q", ErrInvalidMP4, typ)
+	}
+
+	// the size isn't trusted, the buffer grows as the data is read
+	var buf bytes.Buffer
+	buf.Write(header)
+	n, err := io.Copy(&buf, io.LimitReader(r, size-int64(len(header))))
+	if err != nil {
+		return "", nil, err
+	}
+	if n != size-int64(len(header)) {
+		return "", nil, io.ErrUnexpectedEOF
+	}
+	return typ, buf.Bytes(), nil
+}
+
+func unexpectedEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// walkBoxes calls fn for every box of data, descending into containers.
+// start is the offset of the box and payload is the offset of its payload.
+func walkBoxes(data []byte, offset int, fn func(typ string, start, payload, end int) error) error {
+	for len(data) != 0 {
+		typ, headerSize, size, err := boxHeader(data)
+		if err != nil {
+			return err
+		}
+		end := offset + int(size)
+		if err := fn(typ, offset, offset+headerSize, end); err != nil {
+			return err
+		}
+		if containerBoxes[typ] {
+			err := walkBoxes(data[headerSize:size], offset+headerSize, fn)
+			if err != nil {
+				return err
+			}
+		}
+		data = data[size:]
+		offset = end
+	}
+	return nil
+}
+
+func appendUint16(buf []byte, v uint16) []byte {
+	return append(buf, byte(v>>8), byte(v))
+}
+
+func appendUint32(buf []byte, v uint32) []byte {
+	return append(buf, byte(v>>24), byte(v>>16), byte(v>>8), byte(v))
+}
+
+func appendUint64(buf []byte, v uint64) []byte {
+	return appendUint32(appendUint32(buf, uint32(v>>32)), uint32(v))
+}
+
+// boxReader reads fields of a box payload, reporting an error
+// if the payload is too short.
+type boxReader struct {
+	data []byte
+	err  error
+}
+
+func (r *boxReader) next(n int) []byte {
+	if r.err != nil || len(r.data) < n {
+		r.err = ErrInvalidMP4
+		return make([]byte, n)
+	}
+	b := r.data[:n]
+	r.data = r.data[n:]
+	return b
+}
+
+func (r *boxReader) uint8() uint8 {
+	return r.next(1)[0]
+}
+
+func (r *boxReader) uint32() uint32 {
+	return binary.BigEndian.Uint32(r.next(4))
+}
+
+func (r *boxReader) uint64() uint64 {
+	return binary.BigEndian.Uint64(r.next(8))
+}
+
+// versionFlags reads the header of a full box.
+func (r *boxReader) versionFlags() (byte, uint32) {
+	v := r.uint32()
+	return byte(v >> 24), v & 0xFFFFFF
+}
+
+// uintN reads a 64-bit value for version 1 boxes and a 32-bit one otherwise.
+func (r *boxReader) uintN(version byte) uint64 {
+	if version == 1 {
+		return r.uint64()
+	}
+	return uint64(r.uint32())
+}
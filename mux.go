@@ -0,0 +1,1047 @@
+package vimego
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+// MuxOptions configures Mux and MuxDash.
+type MuxOptions struct {
+	// Fragmented keeps the fragments of the streams, so the output is written
+	// while the streams are read. Otherwise the samples are indexed in a moov
+	// box at the front of the file, which is supported by more players but
+	// requires storing the media data in temporary files first.
+	Fragmented bool
+	// TempDir is the directory for the temporary files, os.TempDir() by default.
+	TempDir string
+	// Chapters are written as a chapter track and a Nero chapter list.
+	// The end of the last chapter is needed for fragmented output.
+	Chapters Chapters
+
+	// Reader is used by MuxDash to download the streams. Its Progress
+	// reports both streams together, as a single download.
+	Reader *ReaderOptions
+}
+
+// MuxDash downloads the DASH streams and combines them into a single MP4.
+// Either of the streams may be nil.
+func MuxDash(dst io.Writer, video *DashVideoStream, audio *DashAudioStream, opts *MuxOptions) error {
+	return MuxDashContext(context.Background(), dst, video, audio, opts)
+}
+
+// MuxDashContext is like MuxDash, cancelling ctx stops the downloads.
+func MuxDashContext(ctx context.Context, dst io.Writer, video *DashVideoStream, audio *DashAudioStream, opts *MuxOptions) error {
+	var openVideo, openAudio openFunc
+	if video != nil {
+		openVideo = video.ReaderContext
+	}
+	if audio != nil {
+		openAudio = audio.ReaderContext
+	}
+	return muxStreams(ctx, dst, openVideo, openAudio, opts)
+}
+
+// openFunc opens a reader of a stream.
+type openFunc func(ctx context.Context, opts *ReaderOptions) (io.ReadCloser, int64, error)
+
+// muxStreams downloads the streams and muxes them, either of them may be nil.
+func muxStreams(ctx context.Context, dst io.Writer, openVideo, openAudio openFunc, opts *MuxOptions) error {
+	var readerOpts *ReaderOptions
+	if opts != nil {
+		readerOpts = opts.Reader
+	}
+
+	// both streams are reported as a single download
+	var progress *combinedProgress
+	if readerOpts != nil && openVideo != nil && openAudio != nil {
+		progress = newCombinedProgress(readerOpts.Progress, 2)
+	}
+	open := func(i int, fn openFunc) (io.ReadCloser, error) {
+		o := readerOpts
+		if progress != nil {
+			withPart := *readerOpts
+			withPart.Progress = progress.part(i)
+			o = &withPart
+		}
+		reader, length, err := fn(ctx, o)
+		if err == nil && progress != nil {
+			progress.init(i, readerOpts.Offset, length)
+		}
+		return reader, err
+	}
+
+	var videoReader, audioReader io.Reader
+	if openVideo != nil {
+		reader, err := open(0, openVideo)
+		if err != nil {
+			return err
+		}
+		defer reader.Close()
+		videoReader = reader
+	}
+	if openAudio != nil {
+		reader, err := open(1, openAudio)
+		if err != nil {
+			return err
+		}
+		defer reader.Close()
+		audioReader = reader
+	}
+
+	return Mux(dst, videoReader, audioReader, opts)
+}
+
+// Mux combines fragmented MP4 streams (an init segment followed by media
+// segments, as DASH streams are) into a single MP4 without re-encoding.
+// Either of the streams may be nil.
+func Mux(dst io.Writer, video, audio io.Reader, opts *MuxOptions) error {
+	if opts == nil {
+		opts = &MuxOptions{}
+	}
+
+	m := &muxer{opts: opts}
+	for _, r := range []io.Reader{video, audio} {
+		if r != nil {
+			m.inputs = append(m.inputs, &muxInput{
+				r:  r,
+				id: uint32(len(m.inputs) + 1),
+			})
+		}
+	}
+	if len(m.inputs) == 0 {
+		return errors.New("nothing to mux")
+	}
+
+	w := bufio.NewWriterSize(dst, 1<<20)
+	m.w = &countingWriter{w: w}
+	err := m.mux()
+	if err != nil {
+		return err
+	}
+	return w.Flush()
+}
+
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	n, err := w.w.Write(p)
+	w.n += int64(n)
+	return n, err
+}
+
+type muxer struct {
+	opts     *MuxOptions
+	inputs   []*muxInput
+	w        *countingWriter
+	sequence uint32
+	// the chunks of all the tracks in the output order,
+	// used only for defragmented output
+	chunks []*muxChunk
+}
+
+// muxInput is an input stream containing a single track.
+type muxInput struct {
+	r   io.Reader
+	pos int64 // the position in the input stream
+	id  uint32
+
+	mvhd      *box
+	trak      *box
+	trex      *box
+	inputID   uint32 // the track ID in the input stream
+	timescale uint32 // the media timescale
+	defaults  trackDefaults
+	time      uint64 // the decode time of the next fragment
+	fragment  *muxFragment
+
+	// used only for defragmented output
+	file    *os.File
+	buf     *bufio.Writer
+	written int64
+	samples muxSamples
+	chunks  []*muxChunk
+}
+
+type trackDefaults struct {
+	descriptionIndex uint32
+	duration         uint32
+	size             uint32
+	flags            uint32
+}
+
+// muxFragment is a moof box with the following media data.
+type muxFragment struct {
+	data []byte
+	pos  int64 // the position in the input stream
+	time uint64
+	runs []*muxRun
+}
+
+type muxRun struct {
+	descriptionIndex uint32
+	samples          []muxSample
+}
+
+type muxSample struct {
+	pos      int64 // the position in the input stream
+	size     uint32
+	duration uint32
+	flags    uint32
+	cts      int32
+}
+
+type muxSamples struct {
+	durations []uint32
+	sizes     []uint32
+	nonSync   []uint32 // 1-based numbers of the non-sync samples
+	cts       []int32
+	hasCts    bool
+}
+
+type muxChunk struct {
+	input            *muxInput
+	offset           int64 // the position in the temporary file
+	size             int64
+	samples          uint32
+	descriptionIndex uint32
+}
+
+func (m *muxer) mux() error {
+	for _, in := range m.inputs {
+		err := in.readInit()
+		if err != nil {
+			return err
+		}
+		if !m.opts.Fragmented {
+			in.file, err = os.CreateTemp(m.opts.TempDir, "vimego-*.mdat")
+			if err != nil {
+				return err
+			}
+			defer os.Remove(in.file.Name())
+			defer in.file.Close()
+			in.buf = bufio.NewWriterSize(in.file, 1<<20)
+		}
+		if err := in.readFragment(); err != nil {
+			return err
+		}
+	}
+
+	if m.opts.Fragmented {
+		if err := m.writeFragmentedHeader(); err != nil {
+			return err
+		}
+	}
+
+	for {
+		// take the earliest fragment of all the inputs
+		var in *muxInput
+		for _, input := range m.inputs {
+			if input.fragment == nil {
+				continue
+			}
+			if in == nil || input.fragmentTime() < in.fragmentTime() {
+				in = input
+			}
+		}
+		if in == nil {
+			break
+		}
+
+		var err error
+		if m.opts.Fragmented {
+			err = m.writeFragment(in)
+		} else {
+			err = m.storeFragment(in)
+		}
+		if err != nil {
+			return err
+		}
+		if err := in.readFragment(); err != nil {
+			return err
+		}
+	}
+
+	if m.opts.Fragmented {
+		return nil
+	}
+	return m.writeDefragmented()
+}
+
+// fragmentTime returns the decode time of the current fragment in seconds.
+func (in *muxInput) fragmentTime() float64 {
+	return float64(in.fragment.time) / float64(in.timescale)
+}
+
+// readInit reads the boxes up to moov.
+func (in *muxInput) readInit() error {
+	for {
+		typ, data, err := readBox(in.r)
+		if err != nil {
+			return fmt.Errorf("couldn't read init segment: %w", unexpectedEOF(err))
+		}
+		in.pos += int64(len(data))
+
+		if typ == "moov" {
+			boxes, err := parseBoxes(data)
+			if err != nil {
+				return err
+			}
+			return in.parseMoov(boxes[0])
+		}
+	}
+}
+
+func (in *muxInput) parseMoov(moov *box) error {
+	in.mvhd = moov.child("mvhd")
+	in.trak = moov.child("trak")
+	if in.mvhd == nil || in.trak == nil {
+		return fmt.Errorf("%w: no track found", ErrInvalidMP4)
+	}
+
+	tkhd := in.trak.child("tkhd")
+	mdhd := in.trak.path("mdia", "mdhd")
+	if tkhd == nil || mdhd == nil {
+		return fmt.Errorf("%w: no tkhd or mdhd", ErrInvalidMP4)
+	}
+	if len(in.mvhd.data) == 0 || len(in.mvhd.data) < mdhdTimescale(in.mvhd)+12 ||
+		len(tkhd.data) == 0 || len(tkhd.data) < tkhdDuration(tkhd)+8 ||
+		len(mdhd.data) == 0 || len(mdhd.data) < mdhdTimescale(mdhd)+12 {
+		return fmt.Errorf("%w: truncated header", ErrInvalidMP4)
+	}
+	in.inputID = binary.BigEndian.Uint32(tkhd.data[tkhdTrackID(tkhd):])
+	in.timescale = binary.BigEndian.Uint32(mdhd.data[mdhdTimescale(mdhd):])
+	if in.timescale == 0 {
+		return fmt.Errorf("%w: zero timescale", ErrInvalidMP4)
+	}
+
+	in.defaults = trackDefaults{descriptionIndex: 1}
+	if mvex := moov.child("mvex"); mvex != nil {
+		for _, trex := range mvex.children {
+			if trex.typ != "trex" {
+				continue
+			}
+			r := boxReader{data: trex.data}
+			r.versionFlags()
+			if r.uint32() != in.inputID {
+				continue
+			}
+			in.trex = trex
+			in.defaults = trackDefaults{
+				descriptionIndex: r.uint32(),
+				duration:         r.uint32(),
+				size:             r.uint32(),
+				flags:            r.uint32(),
+			}
+			if r.err != nil {
+				return r.err
+			}
+		}
+	}
+	return nil
+}
+
+// readFragment reads the next moof with its mdat.
+// The fragment is nil when the stream is over.
+func (in *muxInput) readFragment() error {
+	in.fragment = nil
+
+	var fragment *muxFragment
+	for {
+		typ, data, err := readBox(in.r)
+		if err == io.EOF && fragment == nil {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("couldn't read fragment: %w", unexpectedEOF(err))
+		}
+
+		switch {
+		case typ == "moof":
+			fragment = &muxFragment{pos: in.pos, data: data}
+		case fragment != nil:
+			// keep everything between moof and mdat,
+			// so the data offsets stay valid
+			fragment.data = append(fragment.data, data...)
+		}
+		in.pos += int64(len(data))
+
+		if typ == "mdat" && fragment != nil {
+			break
+		}
+	}
+
+	if err := in.parseMoof(fragment); err != nil {
+		return err
+	}
+	in.fragment = fragment
+	return nil
+}
+
+// parseMoof reads the samples of the fragment.
+func (in *muxInput) parseMoof(f *muxFragment) error {
+	_, headerSize, size, err := boxHeader(f.data)
+	if err != nil {
+		return err
+	}
+	moof := &box{typ: "moof"}
+	moof.children, err = parseBoxes(f.data[headerSize:size])
+	if err != nil {
+		return err
+	}
+
+	f.time = in.time
+	first := true
+	prevEnd := f.pos
+	for _, traf := range moof.children {
+		if traf.typ != "traf" {
+			continue
+		}
+		tfhd := traf.child("tfhd")
+		if tfhd == nil {
+			return fmt.Errorf("%w: no tfhd", ErrInvalidMP4)
+		}
+
+		r := boxReader{data: tfhd.data}
+		_, flags := r.versionFlags()
+		trackID := r.uint32()
+		defaults := in.defaults
+		base := prevEnd
+		if first || flags&0x020000 != 0 {
+			base = f.pos
+		}
+		if flags&0x000001 != 0 {
+			base = int64(r.uint64())
+		}
+		if flags&0x000002 != 0 {
+			defaults.descriptionIndex = r.uint32()
+		}
+		if flags&0x000008 != 0 {
+			defaults.duration = r.uint32()
+		}
+		if flags&0x000010 != 0 {
+			defaults.size = r.uint32()
+		}
+		if flags&0x000020 != 0 {
+			defaults.flags = r.uint32()
+		}
+		if r.err != nil {
+			return r.err
+		}
+		first = false
+		if trackID != in.inputID {
+			continue
+		}
+
+		if tfdt := traf.child("tfdt"); tfdt != nil {
+			r := boxReader{data: tfdt.data}
+			version, _ := r.versionFlags()
+			in.time = r.uintN(version)
+			if r.err != nil {
+				return r.err
+			}
+			f.time = in.time
+		}
+
+		pos := base
+		for _, trun := range traf.children {
+			if trun.typ != "trun" {
+				continue
+			}
+			run, err := in.parseTrun(trun, base, pos, defaults)
+			if err != nil {
+				return err
+			}
+			f.runs = append(f.runs, run)
+			if n := len(run.samples); n != 0 {
+				last := run.samples[n-1]
+				pos = last.pos + int64(last.size)
+			}
+		}
+		prevEnd = pos
+	}
+	return nil
+}
+
+func (in *muxInput) parseTrun(trun *box, base, pos int64, defaults trackDefaults) (*muxRun, error) {
+	r := boxReader{data: trun.data}
+	_, flags := r.versionFlags()
+	count := r.uint32()
+	if flags&0x000001 != 0 {
+		pos = base + int64(int32(r.uint32()))
+	}
+	firstFlags := defaults.flags
+	if flags&0x000004 != 0 {
+		firstFlags = r.uint32()
+	}
+	if r.err != nil {
+		return nil, r.err
+	}
+	fieldSize := 0
+	for _, flag := range []uint32{0x000100, 0x000200, 0x000400, 0x000800} {
+		if flags&flag != 0 {
+			fieldSize += 4
+		}
+	}
+	if uint64(count)*uint64(fieldSize) > uint64(len(r.data)) {
+		return nil, fmt.Errorf("%w: too many samples", ErrInvalidMP4)
+	}
+
+	run := &muxRun{
+		descriptionIndex: defaults.descriptionIndex,
+		samples:          make([]muxSample, count),
+	}
+	for i := range run.samples {
+		sample := muxSample{
+			pos:      pos,
+			duration: defaults.duration,
+			size:     defaults.size,
+			flags:    defaults.flags,
+		}
+		if i == 0 {
+			sample.flags = firstFlags
+		}
+		if flags&0x000100 != 0 {
+			sample.duration = r.uint32()
+		}
+		if flags&0x000200 != 0 {
+			sample.size = r.uint32()
+		}
+		if flags&0x000400 != 0 {
+			sample.flags = r.uint32()
+		}
+		if flags&0x000800 != 0 {
+			sample.cts = int32(r.uint32())
+		}
+		run.samples[i] = sample
+		pos += int64(sample.size)
+		in.time += uint64(sample.duration)
+	}
+	return run, r.err
+}
+
+// writeFragmentedHeader writes ftyp and moov for fragmented output.
+func (m *muxer) writeFragmentedHeader() error {
+	moov := &box{typ: "moov"}
+	moov.children = append(moov.children, m.mvhd(0))
+	mvex := &box{typ: "mvex"}
+	for _, in := range m.inputs {
+		trak := copyBox(in.trak)
+		setTrackID(trak.child("tkhd"), in.id)
+		moov.children = append(moov.children, trak)
+
+		trex := fullBox("trex", 0, 0, nil)
+		if in.trex != nil {
+			trex.data = append([]byte{}, in.trex.data...)
+		} else {
+			trex.data = appendUint32(trex.data, 0)
+			trex.data = appendUint32(trex.data, 1)
+			trex.data = append(trex.data, make([]byte, 12)...)
+		}
+		binary.BigEndian.PutUint32(trex.data[4:], in.id)
+		mvex.children = append(mvex.children, trex)
+	}
+	moov.children = append(moov.children, mvex)
+
+	ftyp := &box{typ: "ftyp", data: brands("iso6", 0, "iso6", "isom", "mp41")}
+	if _, err := m.w.Write(ftyp.bytes()); err != nil {
+		return err
+	}
+	if len(m.opts.Chapters) == 0 {
+		_, err := m.w.Write(moov.bytes())
+		return err
+	}
+
+	// the chapter track isn't fragmented, its samples follow moov
+	for _, trak := range moov.children[1 : 1+len(m.inputs)] {
+		addChild(trak, 1, m.chapterTref())
+	}
+	timescale := binary.BigEndian.Uint32(moov.children[0].data[mdhdTimescale(moov.children[0]):])
+	moov.children = append(moov.children, m.chapterTrak(0, false, timescale, 0), m.chapterUdta())
+	offset := uint64(ftyp.size() + moov.size() + 8)
+	moov.children[len(moov.children)-2] = m.chapterTrak(offset, false, timescale, 0)
+
+	samples, _ := chapterSamples(m.opts.Chapters)
+	data := moov.bytes()
+	data = appendBoxHeader(data, "mdat", int64(len(samples)+8))
+	data = append(data, samples...)
+	_, err := m.w.Write(data)
+	return err
+}
+
+// writeFragment writes the current fragment of the input,
+// changing its track ID and sequence number.
+func (m *muxer) writeFragment(in *muxInput) error {
+	f := in.fragment
+	m.sequence++
+	outPos := m.w.n
+
+	_, headerSize, size, err := boxHeader(f.data)
+	if err != nil {
+		return err
+	}
+	moof := f.data[:size]
+	err = walkBoxes(moof[headerSize:], headerSize, func(typ string, start, payload, end int) error {
+		if end-payload < 8 {
+			return nil
+		}
+		switch typ {
+		case "mfhd":
+			binary.BigEndian.PutUint32(moof[payload+4:], m.sequence)
+		case "tfhd":
+			if binary.BigEndian.Uint32(moof[payload+4:]) != in.inputID {
+				return nil
+			}
+			binary.BigEndian.PutUint32(moof[payload+4:], in.id)
+			flags := binary.BigEndian.Uint32(moof[payload:]) & 0xFFFFFF
+			if flags&0x000001 != 0 && end-payload >= 16 {
+				base := int64(binary.BigEndian.Uint64(moof[payload+8:]))
+				binary.BigEndian.PutUint64(moof[payload+8:], uint64(outPos+base-f.pos))
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = m.w.Write(f.data)
+	return err
+}
+
+// storeFragment copies the samples of the fragment to the temporary file.
+func (m *muxer) storeFragment(in *muxInput) error {
+	f := in.fragment
+	for _, run := range f.runs {
+		if len(run.samples) == 0 {
+			continue
+		}
+		chunk := &muxChunk{
+			input:            in,
+			offset:           in.written,
+			samples:          uint32(len(run.samples)),
+			descriptionIndex: run.descriptionIndex,
+		}
+		for _, sample := range run.samples {
+			start := sample.pos - f.pos
+			end := start + int64(sample.size)
+			if start < 0 || end > int64(len(f.data)) {
+				return fmt.Errorf("%w: sample is out of the fragment", ErrInvalidMP4)
+			}
+			if _, err := in.buf.Write(f.data[start:end]); err != nil {
+				return err
+			}
+			chunk.size += int64(sample.size)
+			in.samples.add(sample)
+		}
+		in.written += chunk.size
+		in.chunks = append(in.chunks, chunk)
+		m.chunks = append(m.chunks, chunk)
+	}
+	return nil
+}
+
+func (s *muxSamples) add(sample muxSample) {
+	s.durations = append(s.durations, sample.duration)
+	s.sizes = append(s.sizes, sample.size)
+	s.cts = append(s.cts, sample.cts)
+	if sample.cts != 0 {
+		s.hasCts = true
+	}
+	// sample_is_non_sync_sample
+	if sample.flags&0x00010000 != 0 {
+		s.nonSync = append(s.nonSync, uint32(len(s.sizes)))
+	}
+}
+
+func (s *muxSamples) duration() uint64 {
+	var duration uint64
+	for _, d := range s.durations {
+		duration += uint64(d)
+	}
+	return duration
+}
+
+// writeDefragmented writes ftyp, moov and mdat with the stored samples.
+func (m *muxer) writeDefragmented() error {
+	var mediaSize int64
+	for _, in := range m.inputs {
+		if err := in.buf.Flush(); err != nil {
+			return err
+		}
+		mediaSize += in.written
+	}
+
+	ftyp := &box{typ: "ftyp", data: brands("isom", 0x200, "isom", "iso2", "mp41")}
+	mdatHeaderSize := int64(8)
+	if mediaSize+8 > 0xFFFFFFFF {
+		mdatHeaderSize = 16
+	}
+
+	// the size of moov depends on the chunk offset box type
+	co64 := false
+	moov := m.moov(0, co64)
+	dataStart := ftyp.size() + moov.size() + mdatHeaderSize
+	// the chapter samples follow the media
+	dataEnd := mediaSize
+	if len(m.opts.Chapters) != 0 {
+		dataEnd += 8
+	}
+	if dataStart+dataEnd > 0xFFFFFFFF {
+		co64 = true
+		dataStart = ftyp.size() + m.moov(0, co64).size() + mdatHeaderSize
+	}
+	moov = m.moov(dataStart, co64)
+
+	header := ftyp.bytes()
+	header = append(header, moov.bytes()...)
+	header = appendBoxHeader(header, "mdat", mediaSize+mdatHeaderSize)
+	if _, err := m.w.Write(header); err != nil {
+		return err
+	}
+
+	for _, chunk := range m.chunks {
+		data := io.NewSectionReader(chunk.input.file, chunk.offset, chunk.size)
+		if _, err := io.Copy(m.w, data); err != nil {
+			return err
+		}
+	}
+
+	// the samples of the chapter track are in another mdat after the media
+	if len(m.opts.Chapters) != 0 {
+		samples, _ := chapterSamples(m.opts.Chapters)
+		data := appendBoxHeader(nil, "mdat", int64(len(samples)+8))
+		if _, err := m.w.Write(append(data, samples...)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// moov builds the moov box for defragmented output,
+// dataStart is the position of the media data in the output.
+func (m *muxer) moov(dataStart int64, co64 bool) *box {
+	// the chunk offsets in the output order
+	offsets := map[*muxChunk]uint64{}
+	pos := uint64(dataStart)
+	for _, chunk := range m.chunks {
+		offsets[chunk] = pos
+		pos += uint64(chunk.size)
+	}
+
+	movieTimescale := binary.BigEndian.Uint32(m.inputs[0].mvhd.data[mdhdTimescale(m.inputs[0].mvhd):])
+	var movieDuration uint64
+	var traks []*box
+	for _, in := range m.inputs {
+		mediaDuration := in.samples.duration()
+		duration := mediaDuration * uint64(movieTimescale) / uint64(in.timescale)
+		if duration > movieDuration {
+			movieDuration = duration
+		}
+
+		trak := copyBox(in.trak)
+		tkhd := trak.child("tkhd")
+		setTrackID(tkhd, in.id)
+		setDuration(tkhd, tkhdDuration(tkhd), duration)
+		mdhd := trak.path("mdia", "mdhd")
+		setDuration(mdhd, mdhdTimescale(mdhd)+4, mediaDuration)
+		if elst := trak.path("edts", "elst"); elst != nil {
+			fixEditList(elst, duration)
+		}
+
+		var chunkOffsets []uint64
+		for _, chunk := range in.chunks {
+			chunkOffsets = append(chunkOffsets, offsets[chunk])
+		}
+		if minf := trak.path("mdia", "minf"); minf != nil {
+			minf.replace(in.stbl(minf.child("stbl"), chunkOffsets, co64))
+		}
+		traks = append(traks, trak)
+	}
+
+	moov := &box{typ: "moov"}
+	moov.children = append(moov.children, m.mvhd(movieDuration))
+	moov.children = append(moov.children, traks...)
+	if len(m.opts.Chapters) != 0 {
+		for _, trak := range traks {
+			addChild(trak, 1, m.chapterTref())
+		}
+		// pos is the end of the media data
+		moov.children = append(moov.children,
+			m.chapterTrak(pos+8, co64, movieTimescale, movieDuration), m.chapterUdta())
+	}
+	return moov
+}
+
+// mvhd returns the movie header of the first input with the new duration.
+func (m *muxer) mvhd(duration uint64) *box {
+	mvhd := copyBox(m.inputs[0].mvhd)
+	setDuration(mvhd, mdhdTimescale(mvhd)+4, duration)
+	// next_track_ID
+	next := uint32(len(m.inputs) + 1)
+	if len(m.opts.Chapters) != 0 {
+		next++
+	}
+	binary.BigEndian.PutUint32(mvhd.data[len(mvhd.data)-4:], next)
+	return mvhd
+}
+
+// stbl builds the sample table of the input.
+func (in *muxInput) stbl(old *box, chunkOffsets []uint64, co64 bool) *box {
+	stbl := &box{typ: "stbl"}
+	if old != nil {
+		if stsd := old.child("stsd"); stsd != nil {
+			stbl.children = append(stbl.children, stsd)
+		}
+	}
+
+	samples := &in.samples
+
+	// decoding time to sample
+	var stts []byte
+	var entries uint32
+	for i := 0; i < len(samples.durations); {
+		j := i
+		for j < len(samples.durations) && samples.durations[j] == samples.durations[i] {
+			j++
+		}
+		stts = appendUint32(stts, uint32(j-i))
+		stts = appendUint32(stts, samples.durations[i])
+		entries++
+		i = j
+	}
+	stbl.children = append(stbl.children, fullBox("stts", 0, 0, append(appendUint32(nil, entries), stts...)))
+
+	// composition time to sample
+	if samples.hasCts {
+		version := byte(0)
+		for _, cts := range samples.cts {
+			if cts < 0 {
+				version = 1
+			}
+		}
+		var ctts []byte
+		entries = 0
+		for i := 0; i < len(samples.cts); {
+			j := i
+			for j < len(samples.cts) && samples.cts[j] == samples.cts[i] {
+				j++
+			}
+			ctts = appendUint32(ctts, uint32(j-i))
+			ctts = appendUint32(ctts, uint32(samples.cts[i]))
+			entries++
+			i = j
+		}
+		stbl.children = append(stbl.children, fullBox("ctts", version, 0, append(appendUint32(nil, entries), ctts...)))
+	}
+
+	// sync samples, all the samples are sync samples without it
+	if len(samples.nonSync) != 0 {
+		var stss []byte
+		next := 0
+		for i := range samples.sizes {
+			number := uint32(i + 1)
+			if next < len(samples.nonSync) && samples.nonSync[next] == number {
+				next++
+				continue
+			}
+			stss = appendUint32(stss, number)
+		}
+		stbl.children = append(stbl.children, fullBox("stss", 0, 0, append(appendUint32(nil, uint32(len(stss)/4)), stss...)))
+	}
+
+	// sample to chunk
+	var stsc []byte
+	entries = 0
+	for i, chunk := range in.chunks {
+		if i != 0 {
+			prev := in.chunks[i-1]
+			if prev.samples == chunk.samples && prev.descriptionIndex == chunk.descriptionIndex {
+				continue
+			}
+		}
+		stsc = appendUint32(stsc, uint32(i+1))
+		stsc = appendUint32(stsc, chunk.samples)
+		stsc = appendUint32(stsc, chunk.descriptionIndex)
+		entries++
+	}
+	stbl.children = append(stbl.children, fullBox("stsc", 0, 0, append(appendUint32(nil, entries), stsc...)))
+
+	// sample sizes
+	constant := len(samples.sizes) != 0
+	for _, size := range samples.sizes {
+		if size != samples.sizes[0] {
+			constant = false
+			break
+		}
+	}
+	var stsz []byte
+	if constant {
+		stsz = appendUint32(stsz, samples.sizes[0])
+		stsz = appendUint32(stsz, uint32(len(samples.sizes)))
+	} else {
+		stsz = appendUint32(stsz, 0)
+		stsz = appendUint32(stsz, uint32(len(samples.sizes)))
+		for _, size := range samples.sizes {
+			stsz = appendUint32(stsz, size)
+		}
+	}
+	stbl.children = append(stbl.children, fullBox("stsz", 0, 0, stsz))
+
+	// chunk offsets
+	stco := appendUint32(nil, uint32(len(chunkOffsets)))
+	for _, offset := range chunkOffsets {
+		if co64 {
+			stco = appendUint64(stco, offset)
+		} else {
+			stco = appendUint32(stco, uint32(offset))
+		}
+	}
+	if co64 {
+		stbl.children = append(stbl.children, fullBox("co64", 0, 0, stco))
+	} else {
+		stbl.children = append(stbl.children, fullBox("stco", 0, 0, stco))
+	}
+
+	return stbl
+}
+
+// brands returns the payload of the ftyp box.
+func brands(major string, minor uint32, compatible ...string) []byte {
+	data := append([]byte(major), appendUint32(nil, minor)...)
+	for _, brand := range compatible {
+		data = append(data, brand...)
+	}
+	return data
+}
+
+// addChild inserts the child at the index of the children of b.
+func addChild(b *box, index int, child *box) {
+	if index > len(b.children) {
+		index = len(b.children)
+	}
+	b.children = append(b.children, nil)
+	copy(b.children[index+1:], b.children[index:])
+	b.children[index] = child
+}
+
+// copyBox returns a deep copy of the box, so it can be modified.
+func copyBox(b *box) *box {
+	c := &box{typ: b.typ}
+	if b.data != nil {
+		c.data = append([]byte{}, b.data...)
+	}
+	for _, child := range b.children {
+		c.children = append(c.children, copyBox(child))
+	}
+	return c
+}
+
+// tkhdTrackID returns the offset of track_ID in the tkhd payload.
+func tkhdTrackID(tkhd *box) int {
+	if tkhd.data[0] == 1 {
+		return 20
+	}
+	return 12
+}
+
+// tkhdDuration returns the offset of duration in the tkhd payload.
+func tkhdDuration(tkhd *box) int {
+	if tkhd.data[0] == 1 {
+		return 28
+	}
+	return 20
+}
+
+// mdhdTimescale returns the offset of timescale in the mdhd or mvhd payload,
+// it's followed by duration.
+func mdhdTimescale(mdhd *box) int {
+	if mdhd.data[0] == 1 {
+		return 20
+	}
+	return 12
+}
+
+func setTrackID(tkhd *box, id uint32) {
+	binary.BigEndian.PutUint32(tkhd.data[tkhdTrackID(tkhd):], id)
+}
+
+// setDuration sets the duration at the offset of a version 0 or 1 box,
+// version 0 boxes are rewritten as version 1 if the value doesn't fit.
+func setDuration(b *box, offset int, duration uint64) {
+	if b.data[0] == 0 && duration > 0xFFFFFFFF {
+		offset = upgradeBox(b, offset)
+	}
+	if b.data[0] == 1 {
+		binary.BigEndian.PutUint64(b.data[offset:], duration)
+		return
+	}
+	// a box of unknown layout is left in version 0
+	if duration > 0xFFFFFFFF {
+		duration = 0xFFFFFFFF
+	}
+	binary.BigEndian.PutUint32(b.data[offset:], uint32(duration))
+}
+
+// upgradeBox rewrites a version 0 mvhd, mdhd, tkhd or elst as version 1,
+// widening its times to 64 bits. It returns the new offset of the field
+// at offset.
+func upgradeBox(b *box, offset int) int {
+	// the offsets of the fields widened in version 1
+	var wide []int
+	switch b.typ {
+	case "mvhd", "mdhd":
+		// creation_time, modification_time, duration
+		wide = []int{4, 8, 16}
+	case "tkhd":
+		// creation_time, modification_time, duration
+		wide = []int{4, 8, 20}
+	case "elst":
+		// segment_duration and media_time of each entry
+		count := int(binary.BigEndian.Uint32(b.data[4:]))
+		if len(b.data) < 8+count*12 {
+			return offset
+		}
+		for i := 0; i < count; i++ {
+			wide = append(wide, 8+i*12, 12+i*12)
+		}
+	default:
+		return offset
+	}
+
+	data := []byte{1, b.data[1], b.data[2], b.data[3]}
+	newOffset, pos := offset, 4
+	for _, field := range wide {
+		data = append(data, b.data[pos:field]...)
+		if field == offset {
+			newOffset = len(data)
+		}
+		value := uint64(binary.BigEndian.Uint32(b.data[field:]))
+		if b.typ == "elst" && (field-8)%12 == 4 {
+			// media_time is signed, -1 is an empty edit
+			value = uint64(int64(int32(value)))
+		}
+		data = appendUint64(data, value)
+		pos = field + 4
+	}
+	b.data = append(data, b.data[pos:]...)
+	return newOffset
+}
+
+// fixEditList sets the duration of a single edit covering the whole track,
+// which is left empty in fragmented files.
+func fixEditList(elst *box, duration uint64) {
+	r := boxReader{data: elst.data}
+	version, _ := r.versionFlags()
+	if r.uint32() != 1 || r.uintN(version) != 0 || r.err != nil {
+		return
+	}
+	setDuration(elst, 8, duration)
+}
@@ -0,0 +1,294 @@
+package vimego
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"testing"
+)
+
+// testSample returns the content of a sample, unique for every track and sample.
+func testSample(trackID uint32, i int) []byte {
+	return []byte(fmt.Sprintf("track %d sample %d;", trackID, i))
+}
+
+// newTestFmp4 returns a fragmented MP4 with a single track. Every fragment
+// contains samplesPerFragment samples, every second sample is non-sync.
+func newTestFmp4(trackID, timescale uint32, duration uint32, fragments, samplesPerFragment int) []byte {
+	zeros := func(n int) []byte { return make([]byte, n) }
+
+	mvhd := appendUint32(zeros(12), 1000) // version, flags, times
+	mvhd = append(mvhd, zeros(80)...)
+	mvhd = appendUint32(mvhd, trackID+1)
+
+	tkhd := appendUint32(zeros(12), trackID)
+	tkhd = append(tkhd, zeros(68)...)
+
+	mdhd := appendUint32(zeros(12), timescale)
+	mdhd = append(mdhd, zeros(8)...)
+
+	stsd := append(appendUint32(zeros(4), 1), (&box{typ: "test", data: []byte("entry")}).bytes()...)
+	empty := appendUint32(zeros(4), 0)
+	stbl := &box{typ: "stbl", children: []*box{
+		{typ: "stsd", data: stsd},
+		{typ: "stts", data: empty},
+		{typ: "stsc", data: empty},
+		{typ: "stsz", data: append(zeros(4), zeros(8)...)},
+		{typ: "stco", data: empty},
+	}}
+	trex := appendUint32(zeros(4), trackID)
+	trex = appendUint32(trex, 1)
+	trex = appendUint32(trex, duration)
+	trex = append(trex, zeros(8)...)
+
+	moov := &box{typ: "moov", children: []*box{
+		{typ: "mvhd", data: mvhd},
+		{typ: "trak", children: []*box{
+			{typ: "tkhd", data: tkhd},
+			{typ: "mdia", children: []*box{
+				{typ: "mdhd", data: mdhd},
+				{typ: "minf", children: []*box{stbl}},
+			}},
+		}},
+		{typ: "mvex", children: []*box{{typ: "trex", data: trex}}},
+	}}
+
+	out := (&box{typ: "ftyp", data: brands("iso6", 0, "iso6", "dash")}).bytes()
+	out = append(out, moov.bytes()...)
+
+	for f := 0; f < fragments; f++ {
+		var mdat []byte
+		trun := appendUint32(nil, 0x000601) // data offset, sizes and flags
+		trun = appendUint32(trun, uint32(samplesPerFragment))
+		trun = appendUint32(trun, 0) // data offset, set below
+		for i := 0; i < samplesPerFragment; i++ {
+			sample := testSample(trackID, f*samplesPerFragment+i)
+			mdat = append(mdat, sample...)
+			trun = appendUint32(trun, uint32(len(sample)))
+			flags := uint32(0)
+			if i%2 == 1 {
+				flags = 0x00010000
+			}
+			trun = appendUint32(trun, flags)
+		}
+
+		tfhd := appendUint32(nil, 0x020000) // default-base-is-moof
+		tfhd = appendUint32(tfhd, trackID)
+		tfdt := appendUint32([]byte{1, 0, 0, 0}, 0)
+		tfdt = appendUint32(tfdt, uint32(f*samplesPerFragment)*duration)
+
+		moof := &box{typ: "moof", children: []*box{
+			{typ: "mfhd", data: appendUint32(zeros(4), uint32(f+1))},
+			{typ: "traf", children: []*box{
+				{typ: "tfhd", data: tfhd},
+				{typ: "tfdt", data: tfdt},
+				{typ: "trun", data: trun},
+			}},
+		}}
+		binary.BigEndian.PutUint32(trun[8:], uint32(moof.size()+8))
+
+		out = append(out, (&box{typ: "styp", data: brands("msdh", 0)}).bytes()...)
+		out = append(out, moof.bytes()...)
+		out = append(out, (&box{typ: "mdat", data: mdat}).bytes()...)
+	}
+	return out
+}
+
+func readUint32s(data []byte) []uint32 {
+	result := make([]uint32, len(data)/4)
+	for i := range result {
+		result[i] = binary.BigEndian.Uint32(data[i*4:])
+	}
+	return result
+}
+
+func TestMuxDefragmented(t *testing.T) {
+	video := newTestFmp4(7, 90000, 3000, 4, 5)
+	audio := newTestFmp4(1, 48000, 1024, 10, 3)
+
+	var out bytes.Buffer
+	err := Mux(&out, bytes.NewReader(video), bytes.NewReader(audio), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	boxes, err := parseBoxes(out.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(boxes) != 3 || boxes[0].typ != "ftyp" || boxes[1].typ != "moov" || boxes[2].typ != "mdat" {
+		t.Fatal("unexpected top-level boxes")
+	}
+	moov := boxes[1]
+	if moov.child("mvex") != nil {
+		t.Error("moov contains mvex")
+	}
+
+	expected := map[uint32]int{1: 20, 2: 30}
+	var traks int
+	for _, trak := range moov.children {
+		if trak.typ != "trak" {
+			continue
+		}
+		traks++
+		trackID := readUint32s(trak.child("tkhd").data)[3]
+		inputID := map[uint32]uint32{1: 7, 2: 1}[trackID]
+		stbl := trak.path("mdia", "minf", "stbl")
+		if stbl.child("stsd") == nil {
+			t.Fatal("no stsd")
+		}
+
+		stsz := readUint32s(stbl.child("stsz").data)[1:]
+		sizes := stsz[2:]
+		if int(stsz[1]) != expected[trackID] || len(sizes) != expected[trackID] {
+			t.Fatalf("track %d: unexpected number of samples: %d", trackID, stsz[1])
+		}
+		stsc := readUint32s(stbl.child("stsc").data)[2:]
+		offsets := readUint32s(stbl.child("stco").data)[2:]
+
+		// resolve the samples through the chunks
+		sample := 0
+		for chunk, offset := range offsets {
+			perChunk := uint32(0)
+			for i := 0; i < len(stsc); i += 3 {
+				if stsc[i] <= uint32(chunk+1) {
+					perChunk = stsc[i+1]
+				}
+			}
+			pos := int(offset)
+			for i := 0; i < int(perChunk); i++ {
+				data := out.Bytes()[pos : pos+int(sizes[sample])]
+				if !bytes.Equal(data, testSample(inputID, sample)) {
+					t.Fatalf("track %d: sample %d == %q", trackID, sample, data)
+				}
+				pos += int(sizes[sample])
+				sample++
+			}
+		}
+		if sample != expected[trackID] {
+			t.Errorf("track %d: %d samples in chunks", trackID, sample)
+		}
+
+		// every second sample of a fragment is non-sync
+		perFragment := map[uint32]int{1: 5, 2: 3}[trackID]
+		var sync []uint32
+		for i := 0; i < expected[trackID]; i++ {
+			if i%perFragment%2 == 0 {
+				sync = append(sync, uint32(i+1))
+			}
+		}
+		stss := readUint32s(stbl.child("stss").data)[2:]
+		if fmt.Sprint(stss) != fmt.Sprint(sync) {
+			t.Errorf("track %d: stss == %v", trackID, stss)
+		}
+	}
+	if traks != 2 {
+		t.Errorf("traks == %d", traks)
+	}
+}
+
+func TestMuxFragmented(t *testing.T) {
+	video := newTestFmp4(7, 90000, 3000, 4, 5)
+	audio := newTestFmp4(1, 48000, 1024, 10, 3)
+
+	var out bytes.Buffer
+	err := Mux(&out, bytes.NewReader(video), bytes.NewReader(audio), &MuxOptions{Fragmented: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	next := map[uint32]int{}
+	sequence := uint32(0)
+	data := out.Bytes()
+	pos := 0
+	for pos < len(data) {
+		typ, headerSize, size, err := boxHeader(data[pos:])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if typ == "moof" {
+			moof := &box{typ: typ}
+			moof.children, _ = parseBoxes(data[pos+headerSize : pos+int(size)])
+
+			mfhd := readUint32s(moof.child("mfhd").data)
+			if mfhd[1] != sequence+1 {
+				t.Errorf("sequence number %d after %d", mfhd[1], sequence)
+			}
+			sequence = mfhd[1]
+
+			trackID := readUint32s(moof.path("traf", "tfhd").data)[1]
+			inputID := map[uint32]uint32{1: 7, 2: 1}[trackID]
+			trun := readUint32s(moof.path("traf", "trun").data)
+			samplePos := pos + int(trun[2])
+			for i := 0; i < int(trun[1]); i++ {
+				sampleSize := int(trun[3+i*2])
+				sample := data[samplePos : samplePos+sampleSize]
+				if !bytes.Equal(sample, testSample(inputID, next[trackID])) {
+					t.Fatalf("track %d: sample %d == %q", trackID, next[trackID], sample)
+				}
+				samplePos += sampleSize
+				next[trackID]++
+			}
+		}
+		if typ == "styp" || typ == "sidx" {
+			t.Errorf("unexpected box %q", typ)
+		}
+		pos += int(size)
+	}
+
+	if next[1] != 20 || next[2] != 30 {
+		t.Errorf("unexpected number of samples: %v", next)
+	}
+}
+
+func TestMuxInvalid(t *testing.T) {
+	err := Mux(io.Discard, bytes.NewReader([]byte("not an mp4 file")), nil, nil)
+	if err == nil {
+		t.Error("err == nil")
+	}
+}
+
+func TestSetDurationUpgrade(t *testing.T) {
+	const duration = 1 << 33
+
+	mdhd := fullBox("mdhd", 0, 0, []byte{
+		0, 0, 0, 1, 0, 0, 0, 2, 0, 1, 0x5F, 0x90, 0, 0, 0, 5, 0x55, 0xC4, 0, 0,
+	})
+	setDuration(mdhd, mdhdTimescale(mdhd)+4, duration)
+	r := boxReader{data: mdhd.data}
+	if version, _ := r.versionFlags(); version != 1 {
+		t.Fatalf("mdhd version == %d", version)
+	}
+	if created, modified, timescale, d := r.uint64(), r.uint64(), r.uint32(), r.uint64(); created != 1 ||
+		modified != 2 || timescale != 90000 || d != duration || len(mdhd.data) != 36 {
+		t.Errorf("mdhd: %d %d %d %d, %d bytes", created, modified, timescale, d, len(mdhd.data))
+	}
+
+	tkhd := fullBox("tkhd", 0, 3, append([]byte{
+		0, 0, 0, 1, 0, 0, 0, 2, 0, 0, 0, 7, 0, 0, 0, 0, 0, 0, 0, 5,
+	}, make([]byte, 60)...))
+	setDuration(tkhd, tkhdDuration(tkhd), duration)
+	if tkhd.data[0] != 1 || tkhd.data[3] != 3 {
+		t.Fatalf("tkhd version and flags: %v", tkhd.data[:4])
+	}
+	if id := binary.BigEndian.Uint32(tkhd.data[tkhdTrackID(tkhd):]); id != 7 {
+		t.Errorf("tkhd track_ID == %d", id)
+	}
+	if d := binary.BigEndian.Uint64(tkhd.data[tkhdDuration(tkhd):]); d != duration || len(tkhd.data) != 96 {
+		t.Errorf("tkhd duration == %d, %d bytes", d, len(tkhd.data))
+	}
+
+	elst := fullBox("elst", 0, 0, []byte{
+		0, 0, 0, 1, 0, 0, 0, 0, 0xFF, 0xFF, 0xFF, 0xFF, 0, 1, 0, 0,
+	})
+	fixEditList(elst, duration)
+	r = boxReader{data: elst.data}
+	if version, _ := r.versionFlags(); version != 1 {
+		t.Fatalf("elst version == %d", version)
+	}
+	if count, d, mediaTime, rate := r.uint32(), r.uint64(), int64(r.uint64()), r.uint32(); count != 1 ||
+		d != duration || mediaTime != -1 || rate != 0x10000 {
+		t.Errorf("elst: %d %d %d %#x", count, d, mediaTime, rate)
+	}
+}
@@ -0,0 +1,108 @@
+package vimego
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+var (
+	passwordFormPattern = regexp.MustCompile(`<form[^>]+?id=["']pw_form["']`)
+	xsrftPattern        = regexp.MustCompile(`(?:xsrft|"token")["']?\s*[=:]\s*["']([^"']+)["']`)
+	vuidPattern         = regexp.MustCompile(`vuid\s*=\s*["'](\d+\.\d+)["']`)
+)
+
+// isLocked reports whether the page asks for the password of the video.
+func isLocked(page []byte) bool {
+	return passwordFormPattern.Match(page)
+}
+
+// unlock sends the password form found on the video page
+// and returns the unlocked page.
+func (v *Video) unlock(ctx context.Context, page []byte) ([]byte, error) {
+	if v.Password == "" {
+		return nil, ErrPasswordRequired
+	}
+	token := xsrftPattern.FindSubmatch(page)
+	if token == nil {
+		return nil, ErrParsingFailed
+	}
+
+	site := v.Endpoints.withDefaults().Site
+	siteUrl, err := url.Parse(site)
+	if err != nil {
+		return nil, err
+	}
+	// the vuid cookie is set by a script on the page
+	if vuid := vuidPattern.FindSubmatch(page); vuid != nil {
+		v.cookieJar().SetCookies(siteUrl, []*http.Cookie{
+			{Name: "vuid", Value: string(vuid[1])},
+		})
+	}
+
+	form := url.Values{
+		"password": {v.Password},
+		"token":    {string(token[1])},
+	}
+	passwordUrl := fmt.Sprintf("%s/%v/password", site, v.VideoId)
+	req, err := http.NewRequestWithContext(ctx, "POST", passwordUrl, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header = copyHeader(v.Header)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Referer", v.Url)
+
+	resp, err := v.client().Do(req)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+	switch {
+	case resp.StatusCode == http.StatusTeapot || resp.StatusCode == http.StatusUnauthorized:
+		return nil, ErrWrongPassword
+	case resp.StatusCode >= 400:
+		return nil, ErrUnexpectedStatusCode(resp.StatusCode)
+	}
+
+	// the page is unlocked by the cookies set in the response
+	page, status, err := v.get(ctx, v.Url)
+	if err != nil {
+		return nil, err
+	}
+	if isLocked(page) {
+		return nil, ErrWrongPassword
+	}
+	if status >= 400 {
+		return nil, ErrUnexpectedStatusCode(status)
+	}
+	return page, nil
+}
+
+// cookieJar returns the jar keeping the cookies of the video,
+// such as the ones unlocking it.
+func (v *Video) cookieJar() http.CookieJar {
+	v.jarOnce.Do(func() {
+		v.jar, _ = cookiejar.New(nil)
+	})
+	return v.jar
+}
+
+// client returns the HTTP client of the video,
+// it uses the video's cookie jar if it has none.
+func (v *Video) client() *http.Client {
+	client := http.DefaultClient
+	if v.HTTPClient != nil {
+		client = v.HTTPClient
+	}
+	if client.Jar != nil {
+		return client
+	}
+	withJar := *client
+	withJar.Jar = v.cookieJar()
+	return &withJar
+}
@@ -0,0 +1,191 @@
+package vimego
+
+import (
+	"sync"
+	"time"
+)
+
+// progressInterval limits how often ProgressFunc is called.
+const progressInterval = 100 * time.Millisecond
+
+// Progress describes the state of a download.
+type Progress struct {
+	// BytesDone includes the bytes skipped with ReaderOptions.Offset.
+	BytesDone int64
+	// BytesTotal is -1 if the size of the stream is unknown.
+	BytesTotal int64
+	// Segment is the index of the segment being read, Segments is their number.
+	// Both are 0 for progressive downloads.
+	Segment  int
+	Segments int
+	// Throughput is the average speed in bytes per second.
+	Throughput float64
+	// ETA is -1 if it can't be estimated.
+	ETA time.Duration
+}
+
+// ProgressFunc is called as the download advances, at most every 100ms
+// and once the download is finished.
+type ProgressFunc func(Progress)
+
+// progressTracker reports the progress of a single reader.
+// All its methods are no-op for a nil tracker.
+type progressTracker struct {
+	fn       ProgressFunc
+	start    time.Time
+	last     time.Time
+	offset   int64
+	reported bool // whether the current state was reported
+	progress Progress
+}
+
+// newProgressTracker returns nil if fn is nil.
+func newProgressTracker(fn ProgressFunc, offset, length int64, segments int) *progressTracker {
+	if fn == nil {
+		return nil
+	}
+	total := int64(-1)
+	if length >= 0 {
+		total = offset + length
+	}
+	return &progressTracker{
+		fn:     fn,
+		start:  time.Now(),
+		offset: offset,
+		progress: Progress{
+			BytesDone:  offset,
+			BytesTotal: total,
+			Segments:   segments,
+			ETA:        -1,
+		},
+	}
+}
+
+// add records n bytes read from the segment.
+func (p *progressTracker) add(n int, segment int) {
+	if p == nil {
+		return
+	}
+	p.progress.BytesDone += int64(n)
+	p.progress.Segment = segment
+	p.reported = false
+
+	now := time.Now()
+	if now.Sub(p.last) < progressInterval && p.progress.BytesDone != p.progress.BytesTotal {
+		return
+	}
+	p.last = now
+	p.report(now)
+}
+
+// finish reports the final state.
+func (p *progressTracker) finish() {
+	if p == nil || p.reported {
+		return
+	}
+	p.report(time.Now())
+}
+
+func (p *progressTracker) report(now time.Time) {
+	elapsed := now.Sub(p.start).Seconds()
+	if elapsed > 0 {
+		p.progress.Throughput = float64(p.progress.BytesDone-p.offset) / elapsed
+	}
+
+	p.progress.ETA = -1
+	if p.progress.BytesTotal >= 0 {
+		left := p.progress.BytesTotal - p.progress.BytesDone
+		switch {
+		case left <= 0:
+			p.progress.ETA = 0
+		case p.progress.Throughput > 0:
+			p.progress.ETA = time.Duration(float64(left) / p.progress.Throughput * float64(time.Second))
+		}
+	}
+
+	p.reported = true
+	p.fn(p.progress)
+}
+
+// combinedProgress reports the progress of several readers as a single
+// download, e.g. of the video and the audio being muxed. The bytes and
+// the segments are summed and the calls are serialized.
+type combinedProgress struct {
+	mu       sync.Mutex
+	fn       ProgressFunc
+	parts    []Progress
+	reported []bool
+}
+
+// newCombinedProgress returns nil if fn is nil.
+func newCombinedProgress(fn ProgressFunc, n int) *combinedProgress {
+	if fn == nil {
+		return nil
+	}
+	c := &combinedProgress{
+		fn:       fn,
+		parts:    make([]Progress, n),
+		reported: make([]bool, n),
+	}
+	// the size is unknown until the reader is opened
+	for i := range c.parts {
+		c.parts[i] = Progress{BytesTotal: -1, ETA: -1}
+	}
+	return c
+}
+
+// part returns the ProgressFunc of the i-th reader.
+func (c *combinedProgress) part(i int) ProgressFunc {
+	return func(p Progress) {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		c.parts[i] = p
+		c.reported[i] = true
+		c.fn(c.sum())
+	}
+}
+
+// init sets the size of the i-th reader until it reports its progress,
+// so the total is known from the first call.
+func (c *combinedProgress) init(i int, offset, length int64) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.reported[i] {
+		return
+	}
+	total := int64(-1)
+	if length >= 0 {
+		total = offset + length
+	}
+	c.parts[i] = Progress{BytesDone: offset, BytesTotal: total, ETA: -1}
+}
+
+func (c *combinedProgress) sum() Progress {
+	var result Progress
+	for _, p := range c.parts {
+		result.BytesDone += p.BytesDone
+		result.Segment += p.Segment
+		result.Segments += p.Segments
+		result.Throughput += p.Throughput
+		if p.BytesTotal < 0 || result.BytesTotal < 0 {
+			result.BytesTotal = -1
+		} else {
+			result.BytesTotal += p.BytesTotal
+		}
+	}
+
+	result.ETA = -1
+	if result.BytesTotal >= 0 {
+		left := result.BytesTotal - result.BytesDone
+		switch {
+		case left <= 0:
+			result.ETA = 0
+		case result.Throughput > 0:
+			result.ETA = time.Duration(float64(left) / result.Throughput * float64(time.Second))
+		}
+	}
+	return result
+}
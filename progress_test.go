@@ -0,0 +1,133 @@
+package vimego
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestDashReaderProgress(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, strings.TrimSuffix(strings.Replace(r.URL.Path, "/segment-", "[", 1), ".m4s")+"]")
+	}))
+	defer server.Close()
+
+	stream, expected := newTestDashStream(server.URL, 20)
+	var reports []Progress
+	reader, _, err := stream.ReaderWithOptions(&ReaderOptions{
+		HTTPClient:  server.Client(),
+		Concurrency: 4,
+		Offset:      2,
+		Progress: func(p Progress) {
+			reports = append(reports, p)
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reader.Close()
+
+	if _, err := io.Copy(io.Discard, reader); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(reports) == 0 {
+		t.Fatal("progress wasn't reported")
+	}
+	last := reports[len(reports)-1]
+	if last.BytesDone != int64(len(expected)) || last.BytesTotal != int64(len(expected)) {
+		t.Errorf("last report: %+v", last)
+	}
+	if last.Segments != 21 || last.Segment != 20 {
+		t.Errorf("last report: %+v", last)
+	}
+	if last.ETA != 0 {
+		t.Errorf("last.ETA == %v", last.ETA)
+	}
+}
+
+func TestProgressiveReader(t *testing.T) {
+	content := bytes.Repeat([]byte("0123456789"), 10000)
+
+	var mu sync.Mutex
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requests++
+		request := requests
+		mu.Unlock()
+
+		if request == 1 {
+			// send a part of the file and drop the connection
+			w.Header().Set("Content-Length", "100000")
+			w.Write(content[:30000])
+			w.(http.Flusher).Flush()
+			conn, _, _ := w.(http.Hijacker).Hijack()
+			conn.Close()
+			return
+		}
+		http.ServeContent(w, r, "video.mp4", time.Time{}, bytes.NewReader(content))
+	}))
+	defer server.Close()
+
+	var last Progress
+	format := &ProgressiveFormat{URL: server.URL + "/video.mp4"}
+	reader, length, err := format.ReaderWithOptions(&ReaderOptions{
+		HTTPClient: server.Client(),
+		RetryDelay: time.Millisecond,
+		Progress: func(p Progress) {
+			last = p
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reader.Close()
+
+	if length != int64(len(content)) {
+		t.Errorf("length == %d", length)
+	}
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(data, content) {
+		t.Error("data doesn't match")
+	}
+	if requests != 2 {
+		t.Errorf("requests == %d", requests)
+	}
+	if last.BytesDone != int64(len(content)) || last.BytesTotal != int64(len(content)) {
+		t.Errorf("last report: %+v", last)
+	}
+}
+
+func TestCombinedProgress(t *testing.T) {
+	var last Progress
+	progress := newCombinedProgress(func(p Progress) { last = p }, 2)
+	video, audio := progress.part(0), progress.part(1)
+
+	video(Progress{BytesDone: 100, BytesTotal: 400, Segment: 1, Segments: 4, Throughput: 100, ETA: 3 * time.Second})
+	if last.BytesDone != 100 || last.BytesTotal != -1 || last.ETA != -1 {
+		t.Errorf("before the audio is opened: %+v", last)
+	}
+
+	progress.init(1, 0, 100)
+	audio(Progress{BytesDone: 50, BytesTotal: 100, Segment: 1, Segments: 2, Throughput: 100, ETA: time.Second / 2})
+	expected := Progress{BytesDone: 150, BytesTotal: 500, Segment: 2, Segments: 6, Throughput: 200, ETA: 1750 * time.Millisecond}
+	if last != expected {
+		t.Errorf("last == %+v", last)
+	}
+
+	// init doesn't override the reported progress
+	progress.init(1, 0, 1000)
+	video(Progress{BytesDone: 400, BytesTotal: 400, Segment: 4, Segments: 4, ETA: 0})
+	if last.BytesDone != 450 || last.BytesTotal != 500 {
+		t.Errorf("last == %+v", last)
+	}
+}
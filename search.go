@@ -1,6 +1,7 @@
 package vimego
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
@@ -18,13 +19,14 @@ type SearchClient struct {
 
 	Header     map[string][]string
 	HTTPClient *http.Client
+	Endpoints  *Endpoints
 
 	token   string
 	tokenMu sync.Mutex
 }
 
-func (c *SearchClient) getToken() (string, error) {
-	req, _ := http.NewRequest("GET", "https://vimeo.com/_rv/jwt", nil)
+func (c *SearchClient) getToken(ctx context.Context) (string, error) {
+	req, _ := http.NewRequestWithContext(ctx, "GET", c.Endpoints.withDefaults().JWT, nil)
 	req.Header = map[string][]string{"X-Requested-With": {"XMLHttpRequest"}}
 	resp, err := c.HTTPClient.Do(req)
 	if err != nil {
@@ -46,10 +48,15 @@ func (c *SearchClient) getToken() (string, error) {
 
 // Search returns the result from the requested page.
 func (c *SearchClient) Search(query string, page int) (*SearchResult, error) {
+	return c.SearchContext(context.Background(), query, page)
+}
+
+// SearchContext is like Search but uses ctx for the requests.
+func (c *SearchClient) SearchContext(ctx context.Context, query string, page int) (*SearchResult, error) {
 	var token string
 	c.tokenMu.Lock()
 	if c.token == "" {
-		newToken, err := c.getToken()
+		newToken, err := c.getToken(ctx)
 		if err != nil {
 			c.tokenMu.Unlock()
 			return nil, err
@@ -72,8 +79,8 @@ func (c *SearchClient) Search(query string, page int) (*SearchResult, error) {
 	}
 	params.Add("page", fmt.Sprint(page))
 	params.Add("per_page", fmt.Sprint(c.PerPage))
-	req, _ := http.NewRequest("GET", "https://api.vimeo.com/search?"+params.Encode(), nil)
-	req.Header = c.Header
+	req, _ := http.NewRequestWithContext(ctx, "GET", c.Endpoints.withDefaults().API+"/search?"+params.Encode(), nil)
+	req.Header = copyHeader(c.Header)
 	req.Header["Authorization"] = []string{"jwt " + token}
 	resp, err := c.HTTPClient.Do(req)
 	if err != nil {
@@ -95,7 +102,7 @@ func (c *SearchClient) Search(query string, page int) (*SearchResult, error) {
 			var token string
 			c.tokenMu.Lock()
 			if c.token == "" {
-				newToken, err := c.getToken()
+				newToken, err := c.getToken(ctx)
 				if err != nil {
 					c.tokenMu.Unlock()
 					return nil, err
@@ -139,7 +146,9 @@ type SearchResult struct {
 	Data    SearchData `json:"data"`
 }
 
-type SearchData []struct {
+type SearchData []SearchItem
+
+type SearchItem struct {
 	Type    string       `json:"type"`
 	Video   *VideoItem   `json:"clip,omitempty"`
 	People  *PeopleItem  `json:"people,omitempty"`
@@ -147,6 +156,21 @@ type SearchData []struct {
 	Group   *GroupItem   `json:"group,omitempty"`
 }
 
+// Link returns the link of the video, the user, the channel or the group.
+func (i *SearchItem) Link() string {
+	switch {
+	case i.Video != nil:
+		return i.Video.Link
+	case i.People != nil:
+		return i.People.Link
+	case i.Channel != nil:
+		return i.Channel.Link
+	case i.Group != nil:
+		return i.Group.Link
+	}
+	return ""
+}
+
 func (d SearchData) Videos() []*VideoItem {
 	result := []*VideoItem{}
 	for _, item := range d {
@@ -0,0 +1,165 @@
+package vimego
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+)
+
+func TestSearchClient(t *testing.T) {
+	fake := newFakeVimeo(t)
+	client := fake.searchClient()
+
+	result, err := client.Search("Crystal Castles", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Total != 4 || len(result.Data) != 4 {
+		t.Fatalf("result.Total == %d, len(result.Data) == %d", result.Total, len(result.Data))
+	}
+
+	videos := result.Data.Videos()
+	if len(videos) != 1 || videos[0].Link != "https://vimeo.com/206152466" {
+		t.Errorf("videos: %+v", videos)
+	} else if videos[0].Metadata.Connections.Likes.Total != 1562 {
+		t.Errorf("likes == %d", videos[0].Metadata.Connections.Likes.Total)
+	}
+	if people := result.Data.People(); len(people) != 1 || people[0].Name != "Crystal Castles" {
+		t.Errorf("people: %+v", people)
+	}
+	if channels := result.Data.Channels(); len(channels) != 1 || channels[0].Link == "" {
+		t.Errorf("channels: %+v", channels)
+	}
+	if groups := result.Data.Groups(); len(groups) != 1 || groups[0].Link == "" {
+		t.Errorf("groups: %+v", groups)
+	}
+
+	// the token is reused
+	if _, err := client.Search("Crystal Castles", 2); err != nil {
+		t.Fatal(err)
+	}
+	if n := fake.count("/_rv/jwt"); n != 1 {
+		t.Errorf("the token was requested %d times", n)
+	}
+}
+
+func TestSearchTokenRefresh(t *testing.T) {
+	fake := newFakeVimeo(t)
+	client := fake.searchClient()
+	client.token = "expired"
+
+	if _, err := client.Search("Crystal Castles", 1); err != nil {
+		t.Fatal(err)
+	}
+	if n := fake.count("/_rv/jwt"); n != 1 {
+		t.Errorf("the token was requested %d times", n)
+	}
+	if n := fake.count("/search"); n != 2 {
+		t.Errorf("search was requested %d times", n)
+	}
+}
+
+func TestSearchUnauthorized(t *testing.T) {
+	fake := newFakeVimeo(t)
+	fake.rejectTokens = true
+
+	_, err := fake.searchClient().Search("Crystal Castles", 1)
+	if err != ErrUnexpectedStatusCode(http.StatusUnauthorized) {
+		t.Errorf("err == %v", err)
+	}
+}
+
+// iterate returns the links of all the items of the search.
+func iterate(t *testing.T, it *SearchIterator) []string {
+	t.Helper()
+	defer it.Close()
+	var links []string
+	for it.Next() {
+		links = append(links, it.Item().Link())
+	}
+	if err := it.Err(); err != nil {
+		t.Fatal(err)
+	}
+	return links
+}
+
+func TestSearchIterate(t *testing.T) {
+	for _, prefetch := range []bool{false, true} {
+		fake := newFakeVimeo(t)
+		client := fake.searchClient()
+		client.PerPage = 3
+
+		it := client.Iterate(context.Background(), "Crystal Castles")
+		it.Prefetch = prefetch
+		links := iterate(t, it)
+		if len(links) != 4 || links[0] != "https://vimeo.com/206152466" || links[3] != "https://vimeo.com/groups/electronic" {
+			t.Errorf("prefetch %v: links == %q", prefetch, links)
+		}
+		if it.Total() != 4 {
+			t.Errorf("prefetch %v: it.Total() == %d", prefetch, it.Total())
+		}
+		// stops at the total, without requesting an empty page
+		if n := fake.count("/search"); n != 2 {
+			t.Errorf("prefetch %v: search was requested %d times", prefetch, n)
+		}
+	}
+}
+
+func TestSearchIterateDuplicates(t *testing.T) {
+	fake := newFakeVimeo(t)
+	fake.searchShift = 1
+	client := fake.searchClient()
+	client.PerPage = 2
+
+	// the second page repeats the last item of the first one
+	links := iterate(t, client.Iterate(context.Background(), "Crystal Castles"))
+	want := []string{
+		"https://vimeo.com/206152466",
+		"https://vimeo.com/crystalcastles",
+		"https://vimeo.com/channels/musicvideos",
+	}
+	if len(links) != len(want) {
+		t.Fatalf("links == %q", links)
+	}
+	for i := range want {
+		if links[i] != want[i] {
+			t.Errorf("links[%d] == %q", i, links[i])
+		}
+	}
+}
+
+func TestSearchIterateErrors(t *testing.T) {
+	fake := newFakeVimeo(t)
+	fake.rejectTokens = true
+	it := fake.searchClient().Iterate(context.Background(), "Crystal Castles")
+	if it.Next() {
+		t.Error("Next returned true")
+	}
+	if err := it.Err(); err != ErrUnexpectedStatusCode(http.StatusUnauthorized) {
+		t.Errorf("err == %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	it = newFakeVimeo(t).searchClient().Iterate(ctx, "Crystal Castles")
+	if it.Next() {
+		t.Error("Next returned true")
+	}
+	if err := it.Err(); !errors.Is(err, context.Canceled) {
+		t.Errorf("err == %v", err)
+	}
+
+	// Next is false after Close
+	client := newFakeVimeo(t).searchClient()
+	client.PerPage = 2
+	it = client.Iterate(context.Background(), "Crystal Castles")
+	it.Prefetch = true
+	if !it.Next() {
+		t.Fatal(it.Err())
+	}
+	it.Close()
+	if it.Next() || it.Err() != nil {
+		t.Errorf("Next after Close: err == %v", it.Err())
+	}
+}
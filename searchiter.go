@@ -0,0 +1,141 @@
+package vimego
+
+import "context"
+
+// SearchIterator goes through the results of a search, requesting
+// the pages as they are needed. It's not safe for concurrent use.
+//
+//	it := client.Iterate(ctx, "Crystal Castles")
+//	defer it.Close()
+//	for it.Next() {
+//		fmt.Println(it.Item().Link())
+//	}
+//	if err := it.Err(); err != nil {
+//		...
+//	}
+type SearchIterator struct {
+	// Prefetch makes the iterator request the next page while
+	// the current one is read. Set it before the first Next.
+	Prefetch bool
+
+	client *SearchClient
+	query  string
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	page    int
+	total   int
+	items   SearchData
+	item    *SearchItem
+	seen    map[string]bool
+	pending chan searchPage
+	done    bool
+	err     error
+}
+
+type searchPage struct {
+	result *SearchResult
+	err    error
+}
+
+// Iterate returns an iterator over the results of the query. The items
+// repeated on the next pages, as the ranking shifts, are skipped.
+func (c *SearchClient) Iterate(ctx context.Context, query string) *SearchIterator {
+	ctx, cancel := context.WithCancel(ctx)
+	return &SearchIterator{
+		client: c,
+		query:  query,
+		ctx:    ctx,
+		cancel: cancel,
+		seen:   map[string]bool{},
+	}
+}
+
+// Next advances to the next item. It returns false when the results
+// are over or a request failed, Err tells which.
+func (it *SearchIterator) Next() bool {
+	for {
+		for len(it.items) != 0 {
+			item := &it.items[0]
+			it.items = it.items[1:]
+			if link := item.Link(); link != "" {
+				if it.seen[link] {
+					continue
+				}
+				it.seen[link] = true
+			}
+			it.item = item
+			return true
+		}
+		if it.done {
+			it.item = nil
+			return false
+		}
+		it.fetch()
+	}
+}
+
+// Item returns the current item.
+func (it *SearchIterator) Item() *SearchItem {
+	return it.item
+}
+
+// Total returns the total number of results,
+// known after the first call of Next.
+func (it *SearchIterator) Total() int {
+	return it.total
+}
+
+// Err returns the error that stopped the iteration, if any.
+func (it *SearchIterator) Err() error {
+	return it.err
+}
+
+// Close stops the iteration and cancels the prefetch.
+func (it *SearchIterator) Close() {
+	it.done = true
+	it.items = nil
+	it.cancel()
+}
+
+// fetch receives the next page and starts prefetching the one after it.
+func (it *SearchIterator) fetch() {
+	pending := it.pending
+	it.pending = nil
+	if pending == nil {
+		pending = it.request(it.page + 1)
+	}
+	page := <-pending
+	if page.err != nil {
+		it.err = page.err
+		it.Close()
+		return
+	}
+
+	result := page.result
+	it.page++
+	it.total = result.Total
+	it.items = result.Data
+	perPage := result.PerPage
+	if perPage == 0 {
+		perPage = it.client.PerPage
+	}
+	if len(result.Data) == 0 || it.page*perPage >= result.Total {
+		it.done = true
+		it.cancel()
+		return
+	}
+	if it.Prefetch {
+		it.pending = it.request(it.page + 1)
+	}
+}
+
+// request requests the page in the background.
+func (it *SearchIterator) request(page int) chan searchPage {
+	pending := make(chan searchPage, 1)
+	go func() {
+		result, err := it.client.SearchContext(it.ctx, it.query, page)
+		pending <- searchPage{result, err}
+	}()
+	return pending
+}
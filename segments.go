@@ -0,0 +1,589 @@
+package vimego
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+)
+
+const (
+	defaultConcurrency   = 1
+	defaultMaxBufferSize = 32 << 20
+	defaultRetries       = 3
+	defaultRetryDelay    = 500 * time.Millisecond
+	defaultMaxRetryDelay = 30 * time.Second
+
+	progressChunkSize = 32 << 10
+)
+
+// ReaderOptions configures readers of segmented streams.
+type ReaderOptions struct {
+	HTTPClient *http.Client
+
+	// Concurrency is the number of segments downloaded in parallel.
+	// The segments are still written to the reader in order.
+	Concurrency int
+	// MaxBufferSize limits the number of bytes held in memory for the segments
+	// downloaded ahead of the one being read. The next segment in order
+	// is always downloaded, even if it doesn't fit.
+	MaxBufferSize int64
+
+	// Retries is the number of times a failed segment is downloaded again,
+	// a negative value disables retrying.
+	Retries int
+	// RetryDelay is the delay before the first retry, it doubles with every
+	// next one up to MaxRetryDelay. A random jitter is added to each delay.
+	// Retry-After of 429 and 503 responses takes precedence over it.
+	RetryDelay    time.Duration
+	MaxRetryDelay time.Duration
+
+	// StartTime is the position in seconds to start the stream from.
+	// The stream begins with the init segment followed by the segment
+	// containing StartTime, so the result is still playable.
+	StartTime float64
+	// Offset is the number of bytes to skip, e.g. to resume an interrupted
+	// download. Whole segments are skipped and the partial one is requested
+	// with a Range header. It's applied after StartTime.
+	Offset int64
+
+	// Progress is called as the data is read.
+	Progress ProgressFunc
+
+	// Refresh is called when the server rejects the URL of a progressive
+	// or DASH stream with 403 or 410, as it does once a signed URL expires.
+	// It returns the new URL (of the file or the DASH stream), the download
+	// continues from it. See Video.ProgressiveRefresh and Video.DashRefresh.
+	Refresh RefreshFunc
+}
+
+// withClient returns the options with HTTPClient set to client if it's nil,
+// so the streams of a Video use its client by default.
+func (o *ReaderOptions) withClient(client *http.Client) *ReaderOptions {
+	if client == nil || (o != nil && o.HTTPClient != nil) {
+		return o
+	}
+	result := ReaderOptions{}
+	if o != nil {
+		result = *o
+	}
+	result.HTTPClient = client
+	return &result
+}
+
+func (o *ReaderOptions) withDefaults() *ReaderOptions {
+	result := ReaderOptions{}
+	if o != nil {
+		result = *o
+	}
+	if result.HTTPClient == nil {
+		result.HTTPClient = http.DefaultClient
+	}
+	if result.Concurrency <= 0 {
+		result.Concurrency = defaultConcurrency
+	}
+	if result.MaxBufferSize <= 0 {
+		result.MaxBufferSize = defaultMaxBufferSize
+	}
+	if result.Retries == 0 {
+		result.Retries = defaultRetries
+	} else if result.Retries < 0 {
+		result.Retries = 0
+	}
+	if result.RetryDelay <= 0 {
+		result.RetryDelay = defaultRetryDelay
+	}
+	if result.MaxRetryDelay <= 0 {
+		result.MaxRetryDelay = defaultMaxRetryDelay
+	}
+	return &result
+}
+
+// segment is a part of a segmented stream.
+type segment struct {
+	url  string
+	data []byte // inline data, nothing is downloaded
+
+	// offset and length describe the byte range to request,
+	// length is 0 if the whole resource is needed.
+	offset int64
+	length int64
+	// size is the expected size of the segment, 0 if unknown.
+	size int64
+
+	// decode is applied to the downloaded data, e.g. to decrypt it.
+	decode func(ctx context.Context, data []byte) ([]byte, error)
+}
+
+// segmentsLength returns the total size of the segments,
+// or -1 if some of them have unknown size.
+func segmentsLength(segments []*segment) int64 {
+	var length int64
+	for _, seg := range segments {
+		if seg.size == 0 || seg.decode != nil {
+			return -1
+		}
+		length += seg.size
+	}
+	return length
+}
+
+// skipBytes removes the first n bytes of the segments.
+func skipBytes(segments []*segment, n int64) ([]*segment, error) {
+	for len(segments) != 0 && n > 0 {
+		seg := segments[0]
+		if seg.size == 0 || seg.decode != nil {
+			return nil, ErrSeekUnsupported
+		}
+		if n >= seg.size {
+			n -= seg.size
+			segments = segments[1:]
+			continue
+		}
+
+		partial := *seg
+		if partial.data != nil {
+			partial.data = partial.data[n:]
+		} else {
+			partial.offset += n
+			if partial.length != 0 {
+				partial.length -= n
+			}
+		}
+		partial.size -= n
+		segments = append([]*segment{&partial}, segments[1:]...)
+		break
+	}
+	return segments, nil
+}
+
+// segmentReader is returned to the user, closing it stops the downloads.
+type segmentReader struct {
+	*io.PipeReader
+	cancel context.CancelFunc
+}
+
+func (r *segmentReader) Close() error {
+	r.cancel()
+	return r.PipeReader.Close()
+}
+
+// segmentFetcher downloads the segments in parallel and writes them in order.
+type segmentFetcher struct {
+	opts      *ReaderOptions
+	segments  []*segment
+	refresher *urlRefresher
+	progress  *progressTracker
+
+	mu       sync.Mutex
+	cond     *sync.Cond
+	next     int // the next segment to download
+	written  int // the next segment to write
+	reserved int64
+	results  map[int][]byte
+	err      error
+}
+
+// readSegments returns a reader of the concatenated segments.
+// The refresher may be nil.
+func readSegments(ctx context.Context, opts *ReaderOptions, segments []*segment, refresher *urlRefresher) io.ReadCloser {
+	ctx, cancel := context.WithCancel(ctx)
+	r, w := io.Pipe()
+
+	opts = opts.withDefaults()
+	f := &segmentFetcher{
+		opts:      opts,
+		segments:  segments,
+		refresher: refresher,
+		progress: newProgressTracker(
+			opts.Progress, opts.Offset, segmentsLength(segments), len(segments),
+		),
+		results: map[int][]byte{},
+	}
+	f.cond = sync.NewCond(&f.mu)
+
+	go f.run(ctx, cancel, w)
+
+	return &segmentReader{PipeReader: r, cancel: cancel}
+}
+
+func (f *segmentFetcher) run(ctx context.Context, cancel context.CancelFunc, w *io.PipeWriter) {
+	go func() {
+		// wake up everyone waiting if the download is cancelled
+		<-ctx.Done()
+		f.fail(ctx.Err())
+	}()
+
+	var wg sync.WaitGroup
+	for i := 0; i < f.opts.Concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			f.work(ctx)
+		}()
+	}
+
+	err := f.write(w)
+	cancel()
+	wg.Wait()
+
+	if err != nil {
+		_ = w.CloseWithError(err)
+		return
+	}
+	w.Close()
+}
+
+// fail stores the first error and stops the download.
+func (f *segmentFetcher) fail(err error) {
+	f.mu.Lock()
+	if f.err == nil {
+		f.err = err
+	}
+	f.cond.Broadcast()
+	f.mu.Unlock()
+}
+
+// canStart reports whether the next segment may be downloaded.
+func (f *segmentFetcher) canStart() bool {
+	if f.next == f.written {
+		return true
+	}
+	return f.reserved+f.segments[f.next].size <= f.opts.MaxBufferSize
+}
+
+func (f *segmentFetcher) work(ctx context.Context) {
+	for {
+		f.mu.Lock()
+		for f.err == nil && f.next < len(f.segments) && !f.canStart() {
+			f.cond.Wait()
+		}
+		if f.err != nil || f.next >= len(f.segments) {
+			f.mu.Unlock()
+			return
+		}
+		i := f.next
+		seg := f.segments[i]
+		f.next++
+		f.reserved += seg.size
+		f.mu.Unlock()
+
+		data, err := f.fetch(ctx, seg)
+		if err != nil {
+			f.fail(err)
+			return
+		}
+
+		f.mu.Lock()
+		f.results[i] = data
+		f.reserved += int64(len(data)) - seg.size
+		f.cond.Broadcast()
+		f.mu.Unlock()
+	}
+}
+
+func (f *segmentFetcher) write(w io.Writer) error {
+	for i := range f.segments {
+		f.mu.Lock()
+		data, ok := f.results[i]
+		for !ok && f.err == nil {
+			f.cond.Wait()
+			data, ok = f.results[i]
+		}
+		if !ok {
+			err := f.err
+			f.mu.Unlock()
+			return err
+		}
+		delete(f.results, i)
+		f.written = i + 1
+		f.mu.Unlock()
+
+		err := f.writeSegment(w, i, data)
+
+		f.mu.Lock()
+		f.reserved -= int64(len(data))
+		f.cond.Broadcast()
+		f.mu.Unlock()
+
+		if err != nil {
+			return err
+		}
+	}
+	f.progress.finish()
+	return nil
+}
+
+// writeSegment writes the segment in chunks to report the progress.
+func (f *segmentFetcher) writeSegment(w io.Writer, i int, data []byte) error {
+	if f.progress == nil {
+		_, err := w.Write(data)
+		return err
+	}
+	for len(data) != 0 {
+		chunk := data
+		if len(chunk) > progressChunkSize {
+			chunk = chunk[:progressChunkSize]
+		}
+		n, err := w.Write(chunk)
+		f.progress.add(n, i)
+		if err != nil {
+			return err
+		}
+		data = data[n:]
+	}
+	return nil
+}
+
+func (f *segmentFetcher) fetch(ctx context.Context, seg *segment) ([]byte, error) {
+	data := seg.data
+	if data == nil {
+		var err error
+		data, err = f.downloadWithRetries(ctx, seg)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if seg.decode != nil {
+		return seg.decode(ctx, data)
+	}
+	return data, nil
+}
+
+// downloadWithRetries downloads the segment, retrying on transient errors.
+// The segment is only returned when it's complete, so a failed attempt
+// never leaves partial data in the output.
+func (f *segmentFetcher) downloadWithRetries(ctx context.Context, seg *segment) ([]byte, error) {
+	refreshed := false
+	for attempt := 0; ; attempt++ {
+		url, gen := f.refresher.resolve(seg.url)
+		data, err := f.download(ctx, seg, url)
+		if err == nil {
+			return data, nil
+		}
+		if ctx.Err() != nil {
+			// report the cancellation rather than the failed request
+			return nil, ctx.Err()
+		}
+		if f.refresher != nil && !refreshed && isRejectedUrl(err) {
+			// the URL expired, repeat the download from the new one
+			if err := f.refresher.refresh(ctx, gen); err != nil {
+				return nil, err
+			}
+			refreshed = true
+			attempt--
+			continue
+		}
+		if attempt >= f.opts.Retries || !isRetryable(ctx, err) {
+			return nil, unwrapRetryAfter(err)
+		}
+		if err := sleepContext(ctx, f.opts.retryDelay(attempt, err)); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// retryDelay returns the delay before the retry after the given attempt.
+func (o *ReaderOptions) retryDelay(attempt int, err error) time.Duration {
+	if err, ok := err.(*retryAfterError); ok && err.delay > 0 {
+		if err.delay > o.MaxRetryDelay {
+			return o.MaxRetryDelay
+		}
+		return err.delay
+	}
+
+	delay := o.RetryDelay
+	for i := 0; i < attempt && delay < o.MaxRetryDelay; i++ {
+		delay *= 2
+	}
+	delay += time.Duration(rand.Int63n(int64(delay)/2 + 1))
+	if delay > o.MaxRetryDelay {
+		return o.MaxRetryDelay
+	}
+	return delay
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// retryAfterError is returned for responses that asked to retry later.
+type retryAfterError struct {
+	ErrUnexpectedStatusCode
+	delay time.Duration
+}
+
+func (err *retryAfterError) Unwrap() error {
+	return err.ErrUnexpectedStatusCode
+}
+
+func unwrapRetryAfter(err error) error {
+	if err, ok := err.(*retryAfterError); ok {
+		return err.ErrUnexpectedStatusCode
+	}
+	return err
+}
+
+// checkResponse returns an error for unsuccessful responses.
+func checkResponse(resp *http.Response) error {
+	switch {
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusServiceUnavailable:
+		return &retryAfterError{
+			ErrUnexpectedStatusCode: ErrUnexpectedStatusCode(resp.StatusCode),
+			delay:                   parseRetryAfter(resp.Header.Get("Retry-After")),
+		}
+	case resp.StatusCode >= 400:
+		return ErrUnexpectedStatusCode(resp.StatusCode)
+	}
+	return nil
+}
+
+// parseRetryAfter parses the Retry-After header, which is either
+// a number of seconds or an HTTP date.
+func parseRetryAfter(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0
+		}
+		return time.Duration(seconds) * time.Second
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		if delay := time.Until(date); delay > 0 {
+			return delay
+		}
+	}
+	return 0
+}
+
+// isRetryable reports whether the download may succeed if repeated.
+func isRetryable(ctx context.Context, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+
+	var statusErr ErrUnexpectedStatusCode
+	if errors.As(err, &statusErr) {
+		switch int(statusErr) {
+		case http.StatusRequestTimeout, http.StatusTooManyRequests:
+			return true
+		}
+		return statusErr >= 500
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+	return errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, syscall.ECONNRESET)
+}
+
+func (f *segmentFetcher) download(ctx context.Context, seg *segment, url string) ([]byte, error) {
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	if seg.length != 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", seg.offset, seg.offset+seg.length-1))
+	} else if seg.offset != 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", seg.offset))
+	}
+
+	resp, err := f.opts.HTTPClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if err := checkResponse(resp); err != nil {
+		return nil, err
+	}
+
+	var body io.Reader = resp.Body
+	if req.Header.Get("Range") != "" && resp.StatusCode != http.StatusPartialContent {
+		// the server ignored the range, cut it out ourselves
+		_, err = io.CopyN(io.Discard, body, seg.offset)
+		if err != nil {
+			return nil, err
+		}
+		if seg.length != 0 {
+			body = io.LimitReader(body, seg.length)
+		}
+	}
+
+	return io.ReadAll(body)
+}
+
+// segmentReadSeeker implements io.ReadSeekCloser by reopening
+// the stream at the requested offset.
+type segmentReadSeeker struct {
+	open   func(offset int64) (io.ReadCloser, error)
+	length int64
+	offset int64
+	reader io.ReadCloser
+}
+
+func (s *segmentReadSeeker) Read(p []byte) (int, error) {
+	if s.offset >= s.length {
+		return 0, io.EOF
+	}
+	if s.reader == nil {
+		reader, err := s.open(s.offset)
+		if err != nil {
+			return 0, err
+		}
+		s.reader = reader
+	}
+	n, err := s.reader.Read(p)
+	s.offset += int64(n)
+	return n, err
+}
+
+func (s *segmentReadSeeker) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += s.offset
+	case io.SeekEnd:
+		offset += s.length
+	default:
+		return 0, errors.New("invalid whence")
+	}
+	if offset < 0 {
+		return 0, errors.New("negative position")
+	}
+
+	if offset != s.offset && s.reader != nil {
+		s.reader.Close()
+		s.reader = nil
+	}
+	s.offset = offset
+	return offset, nil
+}
+
+func (s *segmentReadSeeker) Close() error {
+	if s.reader == nil {
+		return nil
+	}
+	err := s.reader.Close()
+	s.reader = nil
+	return err
+}
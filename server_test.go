@@ -0,0 +1,452 @@
+package vimego
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+const testVideoId = 206152466
+
+// testExpiry is the expiry of the signed URLs in testdata,
+// it's replaced by serveSigned.
+const testExpiry = "1640995200"
+
+// rewriteTransport sends every request to the test server.
+type rewriteTransport struct {
+	url *url.URL
+}
+
+func (t *rewriteTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.URL.Scheme = t.url.Scheme
+	req.URL.Host = t.url.Host
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func newTestClient(server *httptest.Server) *http.Client {
+	serverUrl, _ := url.Parse(server.URL)
+	return &http.Client{Transport: &rewriteTransport{url: serverUrl}}
+}
+
+var segmentPattern = regexp.MustCompile(`/(\w+)/chop/segment-(\d+)\.m4s$`)
+
+// fakeVimeo serves the responses recorded in testdata.
+// The clients returned by client send the requests to every host to it.
+type fakeVimeo struct {
+	server *httptest.Server
+
+	mu sync.Mutex
+	// forbidden makes the player config require the signature
+	// found on the video page, as for videos with embedding disabled.
+	forbidden bool
+	// hash makes the video unlisted, the requests without it are rejected.
+	hash string
+	// password locks the video page, the config requires the signature as well.
+	password string
+	// private makes the v2 API respond 404, as it does for private videos.
+	private bool
+	// metadataStatus is the status of the v2 API responses if it isn't 0.
+	metadataStatus int
+	// showcasePassword protects the showcase.
+	showcasePassword string
+	// embedDomain makes the player reject the requests from other sites.
+	embedDomain string
+	// brokenSegments maps the segment paths to the status codes to respond with.
+	brokenSegments map[string]int
+	// token is the only valid JWT, rejectTokens makes the search reject all of them.
+	token        string
+	tokens       int
+	rejectTokens bool
+	// searchShift makes the pages after the first start earlier,
+	// as if new results were ranked above the first page.
+	searchShift int
+	// requests counts the requests by path.
+	requests map[string]int
+	// signed is the number of signed responses, their expiry increases.
+	// The URLs expiring until revoked are rejected.
+	signed  int64
+	revoked int64
+}
+
+func newFakeVimeo(t *testing.T) *fakeVimeo {
+	f := &fakeVimeo{
+		brokenSegments: map[string]int{},
+		requests:       map[string]int{},
+	}
+	f.server = httptest.NewServer(http.HandlerFunc(f.serve))
+	t.Cleanup(f.server.Close)
+	return f
+}
+
+func (f *fakeVimeo) client() *http.Client {
+	return newTestClient(f.server)
+}
+
+func (f *fakeVimeo) video() *Video {
+	video := NewVideoFromId(testVideoId)
+	video.HTTPClient = f.client()
+	return video
+}
+
+func (f *fakeVimeo) searchClient() *SearchClient {
+	client := NewSearchClient()
+	client.HTTPClient = f.client()
+	return client
+}
+
+func (f *fakeVimeo) count(path string) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.requests[path]
+}
+
+func (f *fakeVimeo) serve(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.requests[r.URL.Path]++
+
+	video := fmt.Sprint(testVideoId)
+	switch p := r.URL.Path; {
+	case f.isRevoked(p):
+		http.Error(w, "Access denied", http.StatusForbidden)
+	case p == "/api/v2/video/"+video+".json":
+		if f.private || !f.hasHash(r) {
+			http.NotFound(w, r)
+			return
+		}
+		if f.metadataStatus != 0 {
+			w.WriteHeader(f.metadataStatus)
+			return
+		}
+		serveTestdata(w, "metadata.json", 0)
+	case p == "/video/"+video+"/config":
+		if !f.isEmbedAllowed(r) {
+			http.Error(w, "Because of its privacy settings, this video cannot be played here.", http.StatusForbidden)
+			return
+		}
+		if !f.hasHash(r) {
+			http.Error(w, "Sorry, this video does not exist.", http.StatusForbidden)
+			return
+		}
+		if (f.forbidden || f.password != "") && r.URL.Query().Get("s") == "" {
+			http.Error(w, "Because of its privacy settings, this video cannot be played here.", http.StatusForbidden)
+			return
+		}
+		f.serveSigned(w, "config.json")
+	case p == "/video/"+video:
+		if !f.isEmbedAllowed(r) {
+			http.Error(w, "Because of its privacy settings, this video cannot be played here.", http.StatusForbidden)
+			return
+		}
+		if f.password != "" {
+			fmt.Fprint(w, lockedPage)
+			return
+		}
+		f.signed++
+		config := readTestdata("config.json", time.Now().Add(time.Hour).Unix()+f.signed)
+		fmt.Fprintf(w, "<script>window.playerConfig = %s; var fullscreenSupport = true;</script>", config)
+	case p == "/"+video:
+		if f.password != "" && !isUnlocked(r) {
+			fmt.Fprint(w, lockedPage)
+			return
+		}
+		serveTestdata(w, "page.html", 0)
+	case p == "/"+video+"/password":
+		if _, err := r.Cookie("vuid"); err != nil || r.Method != "POST" ||
+			r.PostFormValue("token") != testXsrft {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		if r.PostFormValue("password") != f.password {
+			w.WriteHeader(http.StatusTeapot)
+			return
+		}
+		http.SetCookie(w, &http.Cookie{Name: "unlocked", Value: video, Path: "/"})
+		http.Redirect(w, r, "/"+video, http.StatusSeeOther)
+	case p == "/_rv/jwt":
+		if r.Header.Get("X-Requested-With") != "XMLHttpRequest" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		f.tokens++
+		f.token = fmt.Sprintf("jwt-token-%d", f.tokens)
+		fmt.Fprintf(w, `{"token": %q, "expires_in": 899}`, f.token)
+	case p == "/_rv/viewer":
+		if r.Header.Get("X-Requested-With") != "XMLHttpRequest" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		f.tokens++
+		f.token = fmt.Sprintf("jwt-token-%d", f.tokens)
+		fmt.Fprintf(w, `{"jwt": %q, "xsrft": %q, "vuid": %q}`, f.token, testXsrft, testVuid)
+	case strings.HasPrefix(p, "/albums/") || strings.HasPrefix(p, "/users/") ||
+		strings.HasPrefix(p, "/channels/") || strings.HasPrefix(p, "/groups/"):
+		if f.rejectTokens || r.Header.Get("Authorization") != "jwt "+f.token {
+			w.WriteHeader(http.StatusUnauthorized)
+			fmt.Fprint(w, `{"error": "A valid user token must be passed.", "error_code": 8003}`)
+			return
+		}
+		f.serveListing(w, r)
+	case p == fmt.Sprintf("/showcase/%d/auth", testShowcaseId):
+		if cookie, err := r.Cookie("vuid"); err != nil || cookie.Value != testVuid ||
+			r.Method != "POST" || r.PostFormValue("token") != testXsrft {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		if r.PostFormValue("password") != f.showcasePassword {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		fmt.Fprintf(w, `{"hashed_pass": %q}`, testHashedPass)
+	case p == "/search":
+		if f.rejectTokens || r.Header.Get("Authorization") != "jwt "+f.token {
+			w.WriteHeader(http.StatusUnauthorized)
+			fmt.Fprint(w, `{"error": "A valid user token must be passed.", "error_code": 8003}`)
+			return
+		}
+		f.serveSearch(w, r)
+	case path.Base(p) == "master.json":
+		if !f.hasHash(r) {
+			http.Error(w, "Access denied", http.StatusForbidden)
+			return
+		}
+		f.serveSigned(w, "dash.json")
+	case segmentPattern.MatchString(p):
+		for broken, code := range f.brokenSegments {
+			if strings.HasSuffix(p, broken) {
+				w.WriteHeader(code)
+				return
+			}
+		}
+		match := segmentPattern.FindStringSubmatch(p)
+		fmt.Fprintf(w, "%s segment %s", match[1], match[2])
+	case strings.HasPrefix(p, "/texttrack/") && path.Ext(p) == ".vtt":
+		if r.URL.Query().Get("token") == "" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		serveTestdata(w, "captions.vtt", 0)
+	case path.Ext(p) == ".jpeg":
+		// a sprite sheet as in the player config
+		jpeg.Encode(w, image.NewGray(image.Rect(0, 0, 640, 360)), nil)
+	case path.Ext(p) == ".mp4":
+		fmt.Fprintf(w, "%s file", path.Base(p))
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+const (
+	testXsrft      = "3f2b1c0d.e4a5"
+	testVuid       = "1234567890.987654321"
+	testShowcaseId = 8156389
+	testHashedPass = "5f4dcc3b5aa765d61d8327deb882cf99"
+)
+
+// testListingVideo is an item of the API listings.
+type testListingVideo struct {
+	uri, link string
+}
+
+// testShowcaseVideos are the videos in the showcase,
+// the user and the channel.
+var testShowcaseVideos = []testListingVideo{
+	{"/videos/206152466", "https://vimeo.com/206152466"},
+	{"/videos/206152467:3c5a1f9e0b", "https://vimeo.com/206152467/3c5a1f9e0b"},
+	{"/videos/206152468", "https://vimeo.com/206152468"},
+}
+
+// testGroupVideos have a video without the ID in its link and
+// a live event, which isn't a video.
+var testGroupVideos = append(testShowcaseVideos[:len(testShowcaseVideos):len(testShowcaseVideos)],
+	testListingVideo{"/videos/206152469", "https://vimeo.com/ondemand/kept"},
+	testListingVideo{"/live_events/4419187", "https://vimeo.com/event/4419187"},
+)
+
+// testListings are the API info of the listings by their paths.
+var testListings = map[string]string{
+	"/users/crystalcastles": `{"name": "Crystal Castles", "link": "https://vimeo.com/crystalcastles", "location": "Toronto",
+		"bio": "", "pictures": {"sizes": []}, "metadata": {"connections": {"followers": {"total": 1843}, "videos": {"total": 3}}}}`,
+	"/channels/staffpicks": `{"name": "Vimeo Staff Picks", "link": "https://vimeo.com/channels/staffpicks", "description": "",
+		"pictures": {"sizes": []}, "metadata": {"connections": {"users": {"total": 1923041}, "videos": {"total": 3}}}}`,
+	"/groups/musicvideos": `{"name": "Music Videos", "link": "https://vimeo.com/groups/musicvideos", "description": "",
+		"pictures": {"sizes": []}, "metadata": {"connections": {"users": {"total": 5210}, "videos": {"total": 5}}}}`,
+}
+
+// serveListing serves the info of a showcase, a user, a channel
+// or a group and their videos.
+func (f *fakeVimeo) serveListing(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	query := r.URL.Query()
+	album := fmt.Sprintf("/albums/%d", testShowcaseId)
+	base := strings.TrimSuffix(r.URL.Path, "/videos")
+	if _, ok := testListings[base]; !ok && base != album {
+		http.NotFound(w, r)
+		return
+	}
+	switch {
+	case r.URL.Path == album:
+		view := "anybody"
+		if f.showcasePassword != "" {
+			view = "password"
+		}
+		fmt.Fprintf(w, `{"name": "Music videos", "description": "", "link": "https://vimeo.com/showcase/%d", "privacy": {"view": %q}}`,
+			testShowcaseId, view)
+	case r.URL.Path == base:
+		fmt.Fprint(w, testListings[base])
+	default:
+		if base == album && f.showcasePassword != "" && query.Get("_hashed_pass") != testHashedPass {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		videos := testShowcaseVideos
+		if base == "/groups/musicvideos" {
+			videos = testGroupVideos
+		}
+		page, _ := strconv.Atoi(query.Get("page"))
+		perPage, _ := strconv.Atoi(query.Get("per_page"))
+		start := (page - 1) * perPage
+		if page < 1 || perPage < 1 || start >= len(videos) {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		end := start + perPage
+		next := fmt.Sprintf(`"%s/videos?page=%d"`, base, page+1)
+		if end >= len(videos) {
+			end = len(videos)
+			next = "null"
+		}
+		var data []string
+		for _, video := range videos[start:end] {
+			data = append(data, fmt.Sprintf(`{"uri": %q, "link": %q}`, video.uri, video.link))
+		}
+		fmt.Fprintf(w, `{"total": %d, "page": %d, "per_page": %d, "paging": {"next": %s}, "data": [%s]}`,
+			len(videos), page, perPage, next, strings.Join(data, ", "))
+	}
+}
+
+// serveSearch serves the requested page of the results in search.json.
+func (f *fakeVimeo) serveSearch(w http.ResponseWriter, r *http.Request) {
+	var results struct {
+		Data []json.RawMessage `json:"data"`
+	}
+	if err := json.Unmarshal(readTestdata("search.json", 0), &results); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	query := r.URL.Query()
+	page, _ := strconv.Atoi(query.Get("page"))
+	perPage, _ := strconv.Atoi(query.Get("per_page"))
+	if page < 1 || perPage < 1 {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	start := (page - 1) * perPage
+	if page > 1 {
+		start -= f.searchShift
+	}
+	data := []json.RawMessage{}
+	if start < len(results.Data) {
+		end := start + perPage
+		if end > len(results.Data) {
+			end = len(results.Data)
+		}
+		data = results.Data[start:end]
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"total":    len(results.Data),
+		"page":     page,
+		"per_page": perPage,
+		"data":     data,
+	})
+}
+
+// lockedPage is the video page with the password form.
+const lockedPage = `<!DOCTYPE html>
+<html lang="en">
+<body>
+<form id="pw_form" method="post" action="/206152466/password">
+<input type="password" name="password">
+</form>
+<script>
+window.vimeo = window.vimeo || {};
+window.vimeo.xsrft = "` + testXsrft + `";
+document.cookie = "vuid=" + (vuid = '1234567890.987654321');
+</script>
+</body>
+</html>`
+
+func isUnlocked(r *http.Request) bool {
+	cookie, err := r.Cookie("unlocked")
+	return err == nil && cookie.Value == fmt.Sprint(testVideoId)
+}
+
+func (f *fakeVimeo) isEmbedAllowed(r *http.Request) bool {
+	return f.embedDomain == "" ||
+		strings.HasPrefix(r.Header.Get("Referer"), f.embedDomain+"/") &&
+			r.Header.Get("Origin") == f.embedDomain
+}
+
+func (f *fakeVimeo) hasHash(r *http.Request) bool {
+	return f.hash == "" || r.URL.Query().Get("h") == f.hash
+}
+
+// revoke makes the server reject the URLs signed so far, as if they expired.
+func (f *fakeVimeo) revoke() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.revoked = time.Now().Add(time.Hour).Unix() + f.signed
+}
+
+func (f *fakeVimeo) isRevoked(path string) bool {
+	expires := urlExpiry([]byte(path))
+	return !expires.IsZero() && expires.Unix() <= f.revoked
+}
+
+// serveSigned serves the file with URLs expiring in an hour, later than
+// the previous ones.
+func (f *fakeVimeo) serveSigned(w http.ResponseWriter, name string) {
+	f.signed++
+	serveTestdata(w, name, time.Now().Add(time.Hour).Unix()+f.signed)
+}
+
+// serveTestdata serves the file, replacing testExpiry if expiry isn't 0.
+func serveTestdata(w http.ResponseWriter, name string, expiry int64) {
+	data := readTestdata(name, expiry)
+	if data == nil {
+		http.Error(w, "no such file", http.StatusInternalServerError)
+		return
+	}
+	if strings.HasSuffix(name, ".json") {
+		w.Header().Set("Content-Type", "application/json")
+	}
+	w.Write(data)
+}
+
+// readTestdata returns the file, replacing testExpiry if expiry isn't 0.
+// It returns nil if the file can't be read.
+func readTestdata(name string, expiry int64) []byte {
+	data, err := os.ReadFile("testdata/" + name)
+	if err != nil {
+		return nil
+	}
+	if expiry != 0 {
+		data = bytes.ReplaceAll(data, []byte(testExpiry), []byte(fmt.Sprint(expiry)))
+	}
+	return data
+}
@@ -0,0 +1,176 @@
+package vimego
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+// Showcase is a showcase of videos, formerly called album.
+type Showcase struct {
+	ID int
+	// Password unlocks a password protected showcase. The videos
+	// get it too, in case they are protected by the same password.
+	Password string
+	// PerPage is the number of videos per request, up to 100 (the default).
+	PerPage int
+
+	Header     map[string][]string
+	HTTPClient *http.Client
+	Endpoints  *Endpoints
+	// Cache is passed to the videos.
+	Cache Cache
+
+	session apiSession
+	mu      sync.Mutex
+	// unlocked is set once the privacy is checked, hashedPass
+	// is the password accepted by the API.
+	unlocked   bool
+	hashedPass string
+}
+
+type ShowcaseInfo struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Link        string `json:"link"`
+	Privacy     struct {
+		// View is "anybody", "password", "unlisted" and so on.
+		View string `json:"view"`
+	} `json:"privacy"`
+}
+
+func (s *Showcase) api() *apiClient {
+	return &apiClient{
+		header:     s.Header,
+		httpClient: s.HTTPClient,
+		endpoints:  s.Endpoints,
+		cache:      s.Cache,
+		session:    &s.session,
+	}
+}
+
+// Info returns the name, the description and the privacy of the showcase.
+func (s *Showcase) Info() (*ShowcaseInfo, error) {
+	return s.InfoContext(context.Background())
+}
+
+// InfoContext is like Info but uses ctx for the requests.
+func (s *Showcase) InfoContext(ctx context.Context) (*ShowcaseInfo, error) {
+	params := url.Values{"fields": {"name,description,link,privacy"}}
+	var result ShowcaseInfo
+	err := s.api().get(ctx, fmt.Sprintf("/albums/%v?%s", s.ID, params.Encode()), &result)
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// Videos returns all the videos of the showcase.
+func (s *Showcase) Videos() ([]*Video, error) {
+	return s.VideosContext(context.Background())
+}
+
+// VideosContext is like Videos but uses ctx for the requests.
+func (s *Showcase) VideosContext(ctx context.Context) ([]*Video, error) {
+	return allVideos(ctx, s.PageContext)
+}
+
+// Page returns the videos from the requested page, starting from 1.
+func (s *Showcase) Page(page int) (*VideoPage, error) {
+	return s.PageContext(context.Background(), page)
+}
+
+// PageContext is like Page but uses ctx for the requests.
+func (s *Showcase) PageContext(ctx context.Context, page int) (*VideoPage, error) {
+	hashedPass, err := s.unlock(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	params := url.Values{}
+	if hashedPass != "" {
+		params.Add("_hashed_pass", hashedPass)
+	}
+	result, err := s.api().videoPage(ctx, fmt.Sprintf("/albums/%v/videos", s.ID), page, s.PerPage, params)
+	if err != nil {
+		return nil, err
+	}
+	for _, video := range result.Videos {
+		video.Password = s.Password
+	}
+	return result, nil
+}
+
+// unlock returns the hashed password of a password protected showcase,
+// it's empty for the others.
+func (s *Showcase) unlock(ctx context.Context) (string, error) {
+	s.mu.Lock()
+	unlocked, hashedPass := s.unlocked, s.hashedPass
+	s.mu.Unlock()
+	if unlocked {
+		return hashedPass, nil
+	}
+
+	info, err := s.InfoContext(ctx)
+	if err != nil {
+		return "", err
+	}
+	if info.Privacy.View != "password" {
+		s.mu.Lock()
+		s.unlocked = true
+		s.mu.Unlock()
+		return "", nil
+	}
+	if s.Password == "" {
+		return "", ErrPasswordRequired
+	}
+
+	v, err := s.api().getViewer(ctx, false)
+	if err != nil {
+		return "", err
+	}
+	form := url.Values{
+		"password": {s.Password},
+		"token":    {v.XSRFT},
+	}
+	authUrl := fmt.Sprintf("%s/showcase/%v/auth", s.Endpoints.withDefaults().Site, s.ID)
+	req, err := http.NewRequestWithContext(ctx, "POST", authUrl, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header = copyHeader(s.Header)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("X-Requested-With", "XMLHttpRequest")
+	req.AddCookie(&http.Cookie{Name: "vuid", Value: v.VUID})
+	resp, err := s.HTTPClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusUnauthorized:
+		return "", ErrWrongPassword
+	case resp.StatusCode >= 400:
+		return "", ErrUnexpectedStatusCode(resp.StatusCode)
+	}
+	var result struct {
+		HashedPass string `json:"hashed_pass"`
+	}
+	err = json.NewDecoder(resp.Body).Decode(&result)
+	if err != nil {
+		return "", fmt.Errorf("couldn't decode auth JSON: %w", err)
+	}
+	if result.HashedPass == "" {
+		return "", ErrWrongPassword
+	}
+
+	s.mu.Lock()
+	s.unlocked, s.hashedPass = true, result.HashedPass
+	s.mu.Unlock()
+	return result.HashedPass, nil
+}
@@ -0,0 +1,114 @@
+package vimego
+
+import (
+	"errors"
+	"testing"
+)
+
+func (f *fakeVimeo) showcase() *Showcase {
+	client := NewClient()
+	client.HTTPClient = f.client()
+	return client.NewShowcaseFromId(testShowcaseId)
+}
+
+func TestNewShowcase(t *testing.T) {
+	for _, url := range []string{
+		"https://vimeo.com/showcase/8156389",
+		"vimeo.com/album/8156389/",
+		"https://vimeo.com/showcase/8156389/video/206152466",
+	} {
+		showcase, err := NewShowcase(url)
+		if err != nil {
+			t.Errorf("NewShowcase(%q): %v", url, err)
+			continue
+		}
+		if showcase.ID != testShowcaseId {
+			t.Errorf("NewShowcase(%q).ID == %d", url, showcase.ID)
+		}
+	}
+	for _, url := range []string{
+		"https://vimeo.com/206152466",
+		"https://vimeo.com/showcase/music",
+		"https://example.com/showcase/8156389",
+	} {
+		if _, err := NewShowcase(url); !errors.Is(err, ErrInvalidUrl) {
+			t.Errorf("NewShowcase(%q): err == %v", url, err)
+		}
+	}
+}
+
+func TestShowcaseVideos(t *testing.T) {
+	fake := newFakeVimeo(t)
+	showcase := fake.showcase()
+	showcase.PerPage = 2
+
+	info, err := showcase.Info()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Name != "Music videos" || info.Privacy.View != "anybody" {
+		t.Errorf("info: %+v", info)
+	}
+
+	videos, err := showcase.Videos()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(videos) != 3 {
+		t.Fatalf("len(videos) == %d", len(videos))
+	}
+	if videos[1].VideoId != 206152467 || videos[1].Hash != "3c5a1f9e0b" {
+		t.Errorf("videos[1]: %+v", videos[1])
+	}
+	if videos[0].HTTPClient != showcase.HTTPClient {
+		t.Error("the videos don't use the client of the showcase")
+	}
+	if n := fake.count("/albums/8156389/videos"); n != 2 {
+		t.Errorf("%d pages were requested", n)
+	}
+
+	// the videos are ready for Formats
+	if _, err := videos[0].Formats(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestShowcasePassword(t *testing.T) {
+	fake := newFakeVimeo(t)
+	fake.showcasePassword = "kept"
+	showcase := fake.showcase()
+
+	if _, err := showcase.Videos(); err != ErrPasswordRequired {
+		t.Errorf("err == %v", err)
+	}
+	showcase.Password = "wrong"
+	if _, err := showcase.Videos(); err != ErrWrongPassword {
+		t.Errorf("err == %v", err)
+	}
+
+	showcase.Password = "kept"
+	videos, err := showcase.Videos()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(videos) != 3 || videos[0].Password != "kept" {
+		t.Errorf("%d videos", len(videos))
+	}
+}
+
+func TestShowcaseTokenRefresh(t *testing.T) {
+	fake := newFakeVimeo(t)
+	showcase := fake.showcase()
+	if _, err := showcase.Info(); err != nil {
+		t.Fatal(err)
+	}
+
+	// the token expired
+	fake.token = "jwt-token-new"
+	if _, err := showcase.Page(1); err != nil {
+		t.Fatal(err)
+	}
+	if n := fake.count("/_rv/viewer"); n != 2 {
+		t.Errorf("the viewer was requested %d times", n)
+	}
+}
@@ -0,0 +1,100 @@
+package vimego
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// TextTrack is a caption or subtitle track of the video.
+type TextTrack struct {
+	ID    int    `json:"id"`
+	Lang  string `json:"lang"`
+	Label string `json:"label"`
+	// Kind is "captions" or "subtitles".
+	Kind string `json:"kind"`
+	// URL is the URL of the WebVTT file.
+	URL string `json:"url"`
+	// Provenance tells who made the track, e.g. "user_uploaded".
+	Provenance string `json:"provenance"`
+	Default    bool   `json:"default"`
+}
+
+// AutoGenerated reports whether the track is a transcript generated by Vimeo.
+func (t *TextTrack) AutoGenerated() bool {
+	return strings.Contains(t.Provenance, "generated") ||
+		strings.Contains(strings.ToLower(t.Label), "auto-generated")
+}
+
+type TextTracks []*TextTrack
+
+// TextTracks returns the caption and subtitle tracks of the video.
+func (v *Video) TextTracks() (TextTracks, error) {
+	return v.TextTracksContext(context.Background())
+}
+
+// TextTracksContext is like TextTracks but uses ctx for the requests.
+func (v *Video) TextTracksContext(ctx context.Context) (TextTracks, error) {
+	config, err := v.playerConfig(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var configData struct {
+		Request struct {
+			TextTracks TextTracks `json:"text_tracks"`
+		} `json:"request"`
+	}
+	err = json.Unmarshal(config, &configData)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't decode config JSON: %w", err)
+	}
+
+	// the URLs are relative to the player
+	playerUrl, err := url.Parse(v.Endpoints.withDefaults().Player)
+	if err != nil {
+		return nil, err
+	}
+	tracks := configData.Request.TextTracks
+	for _, track := range tracks {
+		trackUrl, err := url.Parse(track.URL)
+		if err != nil {
+			return nil, err
+		}
+		track.URL = playerUrl.ResolveReference(trackUrl).String()
+	}
+	return tracks, nil
+}
+
+// GetTextTrack returns the WebVTT file of the track.
+func (v *Video) GetTextTrack(track *TextTrack) ([]byte, error) {
+	return v.GetTextTrackContext(context.Background(), track)
+}
+
+// GetTextTrackContext is like GetTextTrack but uses ctx for the request.
+func (v *Video) GetTextTrackContext(ctx context.Context, track *TextTrack) ([]byte, error) {
+	body, status, err := v.get(ctx, track.URL)
+	if err != nil {
+		return nil, err
+	}
+	if status >= 400 {
+		return nil, ErrUnexpectedStatusCode(status)
+	}
+	return body, nil
+}
+
+// GetCues returns the parsed cues of the track.
+func (v *Video) GetCues(track *TextTrack) (Cues, error) {
+	return v.GetCuesContext(context.Background(), track)
+}
+
+// GetCuesContext is like GetCues but uses ctx for the request.
+func (v *Video) GetCuesContext(ctx context.Context, track *TextTrack) (Cues, error) {
+	vtt, err := v.GetTextTrackContext(ctx, track)
+	if err != nil {
+		return nil, err
+	}
+	return ParseVTT(vtt)
+}
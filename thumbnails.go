@@ -0,0 +1,182 @@
+package vimego
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"image"
+	_ "image/jpeg"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// Thumbnails are the thumbnails of the video.
+type Thumbnails struct {
+	// Sizes are sorted by width.
+	Sizes []PictureSize
+	// Base is the URL of the thumbnail without the size.
+	Base string
+}
+
+// Best returns the largest thumbnail.
+func (t *Thumbnails) Best() *PictureSize {
+	if len(t.Sizes) != 0 {
+		return &t.Sizes[len(t.Sizes)-1]
+	}
+	return nil
+}
+
+// URL returns the URL of the thumbnail resized by the CDN. If height
+// is 0, it's chosen to keep the aspect ratio. It returns "" if there
+// is no base URL.
+func (t *Thumbnails) URL(width, height int) string {
+	if t.Base == "" {
+		return ""
+	}
+	if height == 0 {
+		return fmt.Sprintf("%s_%d", t.Base, width)
+	}
+	return fmt.Sprintf("%s_%dx%d", t.Base, width, height)
+}
+
+// Storyboard is the sprite sheet with the frames shown while scrubbing.
+// The frames are evenly spread over the video, row by row.
+type Storyboard struct {
+	URL         string `json:"url"`
+	Width       int    `json:"width"`
+	Height      int    `json:"height"`
+	FrameWidth  int    `json:"frame_width"`
+	FrameHeight int    `json:"frame_height"`
+	Columns     int    `json:"columns"`
+	Frames      int    `json:"frames"`
+	// Duration is the duration of the video.
+	Duration time.Duration `json:"-"`
+}
+
+// Frame returns the bounds of the frame shown at t in the sprite sheet.
+func (s *Storyboard) Frame(t time.Duration) image.Rectangle {
+	if s.Frames <= 0 || s.Columns <= 0 {
+		return image.Rectangle{}
+	}
+	index := 0
+	if s.Duration > 0 {
+		index = int(int64(t) * int64(s.Frames) / int64(s.Duration))
+	}
+	if index < 0 {
+		index = 0
+	} else if index >= s.Frames {
+		index = s.Frames - 1
+	}
+	x := index % s.Columns * s.FrameWidth
+	y := index / s.Columns * s.FrameHeight
+	return image.Rect(x, y, x+s.FrameWidth, y+s.FrameHeight)
+}
+
+// FrameImage returns the frame shown at t, cut from the sprite sheet.
+func (s *Storyboard) FrameImage(sprite image.Image, t time.Duration) image.Image {
+	frame := s.Frame(t).Add(sprite.Bounds().Min)
+	if sub, ok := sprite.(interface {
+		SubImage(image.Rectangle) image.Image
+	}); ok {
+		return sub.SubImage(frame)
+	}
+	return nil
+}
+
+type configImages struct {
+	Request struct {
+		ThumbPreview *Storyboard `json:"thumb_preview"`
+	} `json:"request"`
+	Video struct {
+		Width    int               `json:"width"`
+		Height   int               `json:"height"`
+		Duration int               `json:"duration"`
+		Thumbs   map[string]string `json:"thumbs"`
+	} `json:"video"`
+}
+
+func (v *Video) configImages(ctx context.Context) (*configImages, error) {
+	config, err := v.playerConfig(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var result configImages
+	err = json.Unmarshal(config, &result)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't decode config JSON: %w", err)
+	}
+	return &result, nil
+}
+
+// Thumbnails returns the thumbnails of the video.
+func (v *Video) Thumbnails() (*Thumbnails, error) {
+	return v.ThumbnailsContext(context.Background())
+}
+
+// ThumbnailsContext is like Thumbnails but uses ctx for the requests.
+func (v *Video) ThumbnailsContext(ctx context.Context) (*Thumbnails, error) {
+	images, err := v.configImages(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	video := images.Video
+	result := &Thumbnails{Base: video.Thumbs["base"]}
+	for key, link := range video.Thumbs {
+		width, err := strconv.Atoi(key)
+		if err != nil {
+			continue
+		}
+		size := PictureSize{Width: width, Link: link}
+		if video.Width != 0 {
+			size.Height = width * video.Height / video.Width
+		}
+		result.Sizes = append(result.Sizes, size)
+	}
+	sort.Slice(result.Sizes, func(a, b int) bool {
+		return result.Sizes[a].Width < result.Sizes[b].Width
+	})
+	return result, nil
+}
+
+// Storyboard returns the storyboard of the video.
+func (v *Video) Storyboard() (*Storyboard, error) {
+	return v.StoryboardContext(context.Background())
+}
+
+// StoryboardContext is like Storyboard but uses ctx for the requests.
+func (v *Video) StoryboardContext(ctx context.Context) (*Storyboard, error) {
+	images, err := v.configImages(ctx)
+	if err != nil {
+		return nil, err
+	}
+	storyboard := images.Request.ThumbPreview
+	if storyboard == nil || storyboard.URL == "" {
+		return nil, ErrNoStoryboard
+	}
+	storyboard.Duration = time.Duration(images.Video.Duration) * time.Second
+	return storyboard, nil
+}
+
+// GetStoryboardImage downloads and decodes the sprite sheet.
+func (v *Video) GetStoryboardImage(s *Storyboard) (image.Image, error) {
+	return v.GetStoryboardImageContext(context.Background(), s)
+}
+
+// GetStoryboardImageContext is like GetStoryboardImage but uses ctx for the request.
+func (v *Video) GetStoryboardImageContext(ctx context.Context, s *Storyboard) (image.Image, error) {
+	body, status, err := v.get(ctx, s.URL)
+	if err != nil {
+		return nil, err
+	}
+	if status >= 400 {
+		return nil, ErrUnexpectedStatusCode(status)
+	}
+	sprite, _, err := image.Decode(bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("couldn't decode the storyboard: %w", err)
+	}
+	return sprite, nil
+}
@@ -0,0 +1,75 @@
+package vimego
+
+import (
+	"image"
+	"testing"
+	"time"
+)
+
+func TestVideoThumbnails(t *testing.T) {
+	fake := newFakeVimeo(t)
+
+	thumbnails, err := fake.video().Thumbnails()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(thumbnails.Sizes) != 3 {
+		t.Fatalf("len(thumbnails.Sizes) == %d", len(thumbnails.Sizes))
+	}
+	best := thumbnails.Best()
+	if best.Width != 1280 || best.Height != 720 || best.Link != "https://i.vimeocdn.com/video/622183432-4f1e7b2c_1280" {
+		t.Errorf("thumbnails.Best(): %+v", best)
+	}
+	if url := thumbnails.URL(1920, 1080); url != "https://i.vimeocdn.com/video/622183432-4f1e7b2c_1920x1080" {
+		t.Errorf("thumbnails.URL(1920, 1080) == %q", url)
+	}
+	if url := thumbnails.URL(295, 0); url != "https://i.vimeocdn.com/video/622183432-4f1e7b2c_295" {
+		t.Errorf("thumbnails.URL(295, 0) == %q", url)
+	}
+}
+
+func TestStoryboardFrame(t *testing.T) {
+	storyboard := &Storyboard{
+		FrameWidth:  64,
+		FrameHeight: 36,
+		Columns:     10,
+		Frames:      100,
+		Duration:    200 * time.Second,
+	}
+	for _, test := range []struct {
+		t     time.Duration
+		frame image.Rectangle
+	}{
+		{0, image.Rect(0, 0, 64, 36)},
+		{3 * time.Second, image.Rect(64, 0, 128, 36)},
+		{25 * time.Second, image.Rect(128, 36, 192, 72)},
+		{time.Hour, image.Rect(576, 324, 640, 360)},
+		{-time.Second, image.Rect(0, 0, 64, 36)},
+	} {
+		if frame := storyboard.Frame(test.t); frame != test.frame {
+			t.Errorf("Frame(%v) == %v", test.t, frame)
+		}
+	}
+}
+
+func TestVideoStoryboard(t *testing.T) {
+	fake := newFakeVimeo(t)
+	video := fake.video()
+
+	storyboard, err := video.Storyboard()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if storyboard.Frames != 100 || storyboard.Columns != 10 || storyboard.Duration != 243*time.Second {
+		t.Errorf("storyboard: %+v", storyboard)
+	}
+
+	sprite, err := video.GetStoryboardImage(storyboard)
+	if err != nil {
+		t.Fatal(err)
+	}
+	frame := storyboard.FrameImage(sprite, 2*time.Minute)
+	if bounds := frame.Bounds(); bounds != image.Rect(576, 144, 640, 180) {
+		t.Errorf("frame.Bounds() == %v", bounds)
+	}
+}
@@ -1,27 +1,175 @@
 package vimego
 
 import (
+	"fmt"
+	"net/url"
 	"regexp"
 	"strconv"
+	"strings"
 )
 
-var validationPatterns = []regexp.Regexp{
-	*regexp.MustCompile(`^https://player.vimeo.com/video/(?P<id>\d+)$`),
-	*regexp.MustCompile(`^https://vimeo.com/(?P<id>\d+)$`),
-	*regexp.MustCompile(`^https://vimeo.com/groups/.+?/videos/(?P<id>\d+)$`),
-	*regexp.MustCompile(`^https://vimeo.com/manage/videos/(?P<id>\d+)$`),
-}
-
-func validateUrl(url string) int {
-	for _, pattern := range validationPatterns {
-		match := pattern.FindStringSubmatch(url)
-		if match != nil {
-			id, err := strconv.ParseInt(match[1], 10, 32)
-			if err == nil {
-				return int(id)
-			}
-			panic(err)
+var (
+	idPattern   = regexp.MustCompile(`^\d+$`)
+	hashPattern = regexp.MustCompile(`^[0-9a-f]+$`)
+	namePattern = regexp.MustCompile(`^[\w-]+$`)
+)
+
+// VideoRef is a video referenced by a URL.
+type VideoRef struct {
+	ID int64
+	// Hash is the privacy hash of unlisted videos, empty for the others.
+	Hash string
+	// Kind is the kind of the page the URL points to.
+	Kind URLKind
+}
+
+// ParseURL parses a URL of a Vimeo video. The errors wrap ErrInvalidUrl.
+func ParseURL(rawUrl string) (*VideoRef, error) {
+	u, path, err := splitURL(rawUrl)
+	if err != nil {
+		return nil, err
+	}
+
+	var kind URLKind
+	var id, hash string
+	switch host := vimeoHost(u); host {
+	case "player.vimeo.com":
+		if len(path) == 2 && path[0] == "video" {
+			kind, id, hash = PlayerURL, path[1], u.Query().Get("h")
+		}
+	case "vimeo.com":
+		kind, id, hash = parseVimeoPath(path)
+	default:
+		return nil, fmt.Errorf("%w: %q isn't a Vimeo host", ErrInvalidUrl, u.Host)
+	}
+	if kind == "" || !idPattern.MatchString(id) {
+		return nil, fmt.Errorf("%w: no video ID in %q", ErrInvalidUrl, u.Path)
+	}
+	if hash != "" && !hashPattern.MatchString(hash) {
+		return nil, fmt.Errorf("%w: invalid privacy hash %q", ErrInvalidUrl, hash)
+	}
+
+	videoId, err := strconv.ParseInt(id, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("%w: the video ID %s is out of range", ErrInvalidUrl, id)
+	}
+	return &VideoRef{ID: videoId, Hash: hash, Kind: kind}, nil
+}
+
+// parseShowcaseURL returns the ID of the showcase the URL points to.
+func parseShowcaseURL(rawUrl string) (int, error) {
+	u, path, err := splitURL(rawUrl)
+	if err != nil {
+		return 0, err
+	}
+	if vimeoHost(u) != "vimeo.com" {
+		return 0, fmt.Errorf("%w: %q isn't a Vimeo host", ErrInvalidUrl, u.Host)
+	}
+	if len(path) < 2 || path[0] != "showcase" && path[0] != "album" || !idPattern.MatchString(path[1]) {
+		return 0, fmt.Errorf("%w: no showcase ID in %q", ErrInvalidUrl, u.Path)
+	}
+	id, err := strconv.Atoi(path[1])
+	if err != nil {
+		return 0, fmt.Errorf("%w: the showcase ID %s is out of range", ErrInvalidUrl, path[1])
+	}
+	return id, nil
+}
+
+// reservedPaths are the first elements of the vimeo.com paths
+// that aren't user profiles.
+var reservedPaths = map[string]bool{
+	"album": true, "channels": true, "groups": true, "manage": true,
+	"ondemand": true, "search": true, "showcase": true, "video": true,
+}
+
+// parseListingName returns the name of the user, channel or group
+// in the URL. prefix is "channels" or "groups", empty for users.
+// A plain name is returned as is.
+func parseListingName(rawUrl, prefix string) (string, error) {
+	if name := strings.TrimSpace(rawUrl); namePattern.MatchString(name) {
+		return name, nil
+	}
+	u, path, err := splitURL(rawUrl)
+	if err != nil {
+		return "", err
+	}
+	if vimeoHost(u) != "vimeo.com" {
+		return "", fmt.Errorf("%w: %q isn't a Vimeo host", ErrInvalidUrl, u.Host)
+	}
+
+	var name string
+	switch {
+	case prefix == "" && len(path) != 0 && !reservedPaths[path[0]] && !idPattern.MatchString(path[0]):
+		name = path[0]
+	case prefix != "" && len(path) >= 2 && path[0] == prefix:
+		name = path[1]
+	}
+	if !namePattern.MatchString(name) {
+		return "", fmt.Errorf("%w: no name in %q", ErrInvalidUrl, u.Path)
+	}
+	return name, nil
+}
+
+// splitURL parses the URL, adding https:// if there is no scheme,
+// and returns the non-empty elements of its path.
+func splitURL(rawUrl string) (*url.URL, []string, error) {
+	rawUrl = strings.TrimSpace(rawUrl)
+	if !strings.Contains(rawUrl, "://") {
+		rawUrl = "https://" + rawUrl
+	}
+	u, err := url.Parse(rawUrl)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: %v", ErrInvalidUrl, err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, nil, fmt.Errorf("%w: unsupported scheme %q", ErrInvalidUrl, u.Scheme)
+	}
+
+	var path []string
+	for _, part := range strings.Split(u.Path, "/") {
+		if part != "" {
+			path = append(path, part)
 		}
 	}
-	return 0
+	return u, path, nil
+}
+
+func vimeoHost(u *url.URL) string {
+	return strings.TrimPrefix(strings.ToLower(u.Hostname()), "www.")
+}
+
+// parseVimeoPath returns the kind, the ID and the hash of a vimeo.com URL.
+// The kind is empty if the path doesn't point to a video.
+func parseVimeoPath(path []string) (URLKind, string, string) {
+	switch {
+	case len(path) == 1:
+		return VideoURL, path[0], ""
+	case len(path) == 2 && idPattern.MatchString(path[0]):
+		// the unlisted videos have the hash after the ID
+		return VideoURL, path[0], path[1]
+	case len(path) == 2 && path[0] == "video":
+		return VideoURL, path[1], ""
+	case len(path) == 3 && path[0] == "channels":
+		return ChannelURL, path[2], ""
+	case len(path) == 4 && path[0] == "groups" && path[2] == "videos":
+		return GroupURL, path[3], ""
+	case len(path) == 4 && path[0] == "showcase" && path[2] == "video":
+		return ShowcaseURL, path[3], ""
+	case len(path) == 4 && path[0] == "album" && path[2] == "video":
+		return AlbumURL, path[3], ""
+	case len(path) == 3 && path[0] == "ondemand":
+		return OnDemandURL, path[2], ""
+	case len(path) >= 3 && len(path) <= 4 && path[0] == "manage" && path[1] == "videos":
+		return ManageURL, path[2], pathElement(path, 3)
+	case len(path) >= 3 && len(path) <= 4 && path[1] == "review":
+		return ReviewURL, path[2], pathElement(path, 3)
+	}
+	return "", "", ""
+}
+
+func pathElement(path []string, i int) string {
+	if i < len(path) {
+		return path[i]
+	}
+	return ""
 }
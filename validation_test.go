@@ -0,0 +1,58 @@
+package vimego
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParseURL(t *testing.T) {
+	tests := []struct {
+		url      string
+		expected VideoRef
+	}{
+		{"https://vimeo.com/206152466", VideoRef{ID: 206152466, Kind: VideoURL}},
+		{"http://www.vimeo.com/206152466/", VideoRef{ID: 206152466, Kind: VideoURL}},
+		{"vimeo.com/206152466?share=copy#t=30s", VideoRef{ID: 206152466, Kind: VideoURL}},
+		{"https://vimeo.com/206152466/3c5a1f9e0b", VideoRef{ID: 206152466, Hash: "3c5a1f9e0b", Kind: VideoURL}},
+		{"https://player.vimeo.com/video/206152466", VideoRef{ID: 206152466, Kind: PlayerURL}},
+		{"https://player.vimeo.com/video/206152466?h=3c5a1f9e0b&autoplay=1", VideoRef{ID: 206152466, Hash: "3c5a1f9e0b", Kind: PlayerURL}},
+		{"https://vimeo.com/channels/staffpicks/206152466", VideoRef{ID: 206152466, Kind: ChannelURL}},
+		{"https://vimeo.com/groups/music/videos/206152466", VideoRef{ID: 206152466, Kind: GroupURL}},
+		{"https://vimeo.com/showcase/8156389/video/206152466", VideoRef{ID: 206152466, Kind: ShowcaseURL}},
+		{"https://vimeo.com/album/3951494/video/206152466", VideoRef{ID: 206152466, Kind: AlbumURL}},
+		{"https://vimeo.com/ondemand/thefilm/206152466", VideoRef{ID: 206152466, Kind: OnDemandURL}},
+		{"https://vimeo.com/manage/videos/206152466", VideoRef{ID: 206152466, Kind: ManageURL}},
+		{"https://vimeo.com/manage/videos/206152466/3c5a1f9e0b", VideoRef{ID: 206152466, Hash: "3c5a1f9e0b", Kind: ManageURL}},
+		{"https://vimeo.com/user123/review/206152466/3c5a1f9e0b", VideoRef{ID: 206152466, Hash: "3c5a1f9e0b", Kind: ReviewURL}},
+		{"https://vimeo.com/9999999999", VideoRef{ID: 9999999999, Kind: VideoURL}},
+	}
+	for _, test := range tests {
+		ref, err := ParseURL(test.url)
+		if err != nil {
+			t.Errorf("%s: %v", test.url, err)
+			continue
+		}
+		if *ref != test.expected {
+			t.Errorf("%s: %+v", test.url, *ref)
+		}
+	}
+}
+
+func TestParseURLInvalid(t *testing.T) {
+	for _, url := range []string{
+		"",
+		"https://youtube.com/watch?v=dQw4w9WgXcQ",
+		"ftp://vimeo.com/206152466",
+		"https://vimeo.com/",
+		"https://vimeo.com/crystalcastles",
+		"https://vimeo.com/206152466/likes",
+		"https://vimeo.com/channels/staffpicks",
+		"https://vimeo.com/99999999999999999999",
+		"https://player.vimeo.com/video/206152466?h=<script>",
+	} {
+		ref, err := ParseURL(url)
+		if !errors.Is(err, ErrInvalidUrl) {
+			t.Errorf("%s: %+v, %v", url, ref, err)
+		}
+	}
+}
@@ -1,7 +1,10 @@
 package vimego
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -9,40 +12,88 @@ import (
 	"regexp"
 	"sort"
 	"strings"
+	"sync"
 )
 
 type Video struct {
 	Url     string
 	VideoId int
+	// Hash is the privacy hash of an unlisted video.
+	Hash string
+	// Password unlocks a password protected video.
+	Password string
+	// EmbedOrigin is the URL of the page embedding the video. It's sent
+	// to the player as Referer and Origin, for the videos embeddable
+	// only on specific domains.
+	EmbedOrigin string
 
 	Header     map[string][]string
 	HTTPClient *http.Client
+	Endpoints  *Endpoints
+	// Cache is used for the metadata, the player config and the DASH JSON.
+	Cache Cache
+
+	jarOnce sync.Once
+	jar     http.CookieJar
 }
 
 // Metadata returns the video metadata.
+// If the API has none, as for private, unlisted and embed-only videos,
+// it's built from the player config, see PlayerMetadata.
 func (v *Video) Metadata() (*Metadata, error) {
-	req, _ := http.NewRequest(
-		"GET",
-		fmt.Sprintf("http://vimeo.com/api/v2/video/%v.json",
-			v.VideoId),
-		nil,
-	)
-	req.Header = v.Header
-	resp, err := v.HTTPClient.Do(req)
+	return v.MetadataContext(context.Background())
+}
+
+// MetadataContext is like Metadata but uses ctx for the requests.
+func (v *Video) MetadataContext(ctx context.Context) (*Metadata, error) {
+	metadata, err := v.apiMetadata(ctx)
+	// the API doesn't serve private and embed-only videos,
+	// the other errors such as rate limits are returned
+	var statusErr ErrUnexpectedStatusCode
+	if errors.As(err, &statusErr) && (statusErr == http.StatusForbidden || statusErr == http.StatusNotFound) ||
+		errors.Is(err, ErrParsingFailed) {
+		return v.PlayerMetadataContext(ctx)
+	}
+	return metadata, err
+}
+
+// PlayerMetadata returns the video metadata found in the player config.
+// The description, upload date, stats and tags are empty.
+func (v *Video) PlayerMetadata() (*Metadata, error) {
+	return v.PlayerMetadataContext(context.Background())
+}
+
+// PlayerMetadataContext is like PlayerMetadata but uses ctx for the requests.
+func (v *Video) PlayerMetadataContext(ctx context.Context) (*Metadata, error) {
+	config, err := v.playerConfig(ctx)
 	if err != nil {
 		return nil, err
 	}
-	defer resp.Body.Close()
+	return configMetadata(config)
+}
 
-	if resp.StatusCode >= 400 {
-		return nil, ErrUnexpectedStatusCode(resp.StatusCode)
+// apiMetadata returns the video metadata from the v2 API.
+func (v *Video) apiMetadata(ctx context.Context) (*Metadata, error) {
+	metadataUrl := fmt.Sprintf("%s/video/%v.json", v.Endpoints.withDefaults().V2, v.VideoId)
+	body, err := v.cached(v.cacheKey("metadata"), func() ([]byte, error) {
+		body, status, err := v.get(ctx, v.withHash(metadataUrl))
+		if err == nil && status >= 400 {
+			err = ErrUnexpectedStatusCode(status)
+		}
+		return body, err
+	})
+	if err != nil {
+		return nil, err
 	}
 
 	var result []*Metadata
-	err = json.NewDecoder(resp.Body).Decode(&result)
+	err = json.Unmarshal(body, &result)
 	if err != nil {
 		return nil, fmt.Errorf("couldn't decode metadata JSON: %w", err)
 	}
+	if len(result) == 0 {
+		return nil, ErrParsingFailed
+	}
 
 	return result[0], nil
 }
@@ -52,95 +103,252 @@ func (v *Video) Metadata() (*Metadata, error) {
 // Hls format contains an URL to .m3u8 playlist with all possible streams.
 // Dash format contains a JSON URL that can be parsed using GetDashStreams.
 func (v *Video) Formats() (*VideoFormats, error) {
-	configUrl := fmt.Sprintf("https://player.vimeo.com/video/%v/config", v.VideoId)
-	req, _ := http.NewRequest("GET", configUrl, nil)
-	req.Header = v.Header
-	resp, err := v.HTTPClient.Do(req)
+	return v.FormatsContext(context.Background())
+}
+
+// FormatsContext is like Formats but uses ctx for the requests.
+func (v *Video) FormatsContext(ctx context.Context) (*VideoFormats, error) {
+	config, err := v.playerConfig(ctx)
 	if err != nil {
 		return nil, err
 	}
-	defer resp.Body.Close()
+	return v.parseFormats(config)
+}
+
+// refreshFormats fetches the player config again, bypassing the cache.
+func (v *Video) refreshFormats(ctx context.Context) (*VideoFormats, error) {
+	config, err := v.fetchPlayerConfig(ctx)
+	if err != nil {
+		return nil, err
+	}
+	v.store(v.playerConfigKey(), config)
+	return v.parseFormats(config)
+}
 
+func (v *Video) parseFormats(config []byte) (*VideoFormats, error) {
 	var configData struct {
 		Request struct {
 			Files *VideoFormats `json:"files"`
 		} `json:"request"`
 	}
-
-	if resp.StatusCode < 400 {
-		err = json.NewDecoder(resp.Body).Decode(&configData)
-		if err != nil {
-			return nil, fmt.Errorf("couldn't decode config JSON: %w", err)
-		}
-	} else {
-		if resp.StatusCode == 403 {
-			// If the response is forbidden it tries another way to fetch link
-			req, _ := http.NewRequest("GET", v.Url, nil)
-			req.Header = v.Header
-			resp, err := v.HTTPClient.Do(req)
-			if err != nil {
-				return nil, err
-			}
-			defer resp.Body.Close()
-
-			if resp.StatusCode < 400 {
-				pattern := fmt.Sprintf(
-					`"(%s.+?)"`,
-					strings.ReplaceAll(configUrl, "/", `\\/`),
-				)
-				rexp, err := regexp.Compile(pattern)
-				if err != nil {
-					return nil, ErrParsingFailed
-				}
-				body, err := io.ReadAll(resp.Body)
-				if err != nil {
-					return nil, ErrParsingFailed
-				}
-				configUrls := rexp.FindAll(body, 1)
-				if len(configUrls) == 0 {
-					return nil, ErrParsingFailed
-				}
-				configUrl := strings.Trim(strings.ReplaceAll(string(configUrls[0]), `\/`, "/"), `"`)
-				req, err := http.NewRequest("GET", configUrl, nil)
-				if err != nil {
-					return nil, ErrParsingFailed
-				}
-				req.Header = v.Header
-				resp, err := v.HTTPClient.Do(req)
-				if err != nil {
-					return nil, err
-				}
-				defer resp.Body.Close()
-				err = json.NewDecoder(resp.Body).Decode(&configData)
-				if err != nil {
-					return nil, fmt.Errorf("couldn't decode config JSON: %w", err)
-				}
-			} else {
-				return nil, ErrParsingFailed
-			}
-		} else {
-			return nil, ErrParsingFailed
-		}
+	err := json.Unmarshal(config, &configData)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't decode config JSON: %w", err)
 	}
 	if configData.Request.Files == nil {
 		return nil, ErrParsingFailed
 	}
 	sort.Sort(configData.Request.Files.Progressive)
+	for _, format := range configData.Request.Files.Progressive {
+		format.httpClient = v.HTTPClient
+	}
 	return configData.Request.Files, nil
 }
 
+// playerConfig returns the player config JSON.
+func (v *Video) playerConfig(ctx context.Context) ([]byte, error) {
+	return v.cached(v.playerConfigKey(), func() ([]byte, error) {
+		return v.fetchPlayerConfig(ctx)
+	})
+}
+
+func (v *Video) playerConfigKey() string {
+	return v.cacheKey("config")
+}
+
+// cacheKey returns the key of the video's data of the given kind.
+func (v *Video) cacheKey(kind string) string {
+	if v.Hash != "" {
+		return fmt.Sprintf("%s:%v:%s", kind, v.VideoId, v.Hash)
+	}
+	return fmt.Sprintf("%s:%v", kind, v.VideoId)
+}
+
+// withHash adds the privacy hash of an unlisted video to the URL.
+func (v *Video) withHash(rawUrl string) string {
+	if v.Hash == "" {
+		return rawUrl
+	}
+	u, err := url.Parse(rawUrl)
+	if err != nil {
+		return rawUrl
+	}
+	query := u.Query()
+	if query.Get("h") != "" {
+		return rawUrl
+	}
+	query.Set("h", v.Hash)
+	u.RawQuery = query.Encode()
+	return u.String()
+}
+
+func (v *Video) fetchPlayerConfig(ctx context.Context) ([]byte, error) {
+	configUrl := fmt.Sprintf("%s/video/%v/config", v.Endpoints.withDefaults().Player, v.VideoId)
+	body, status, err := v.get(ctx, v.withHash(configUrl))
+	if err != nil {
+		return nil, err
+	}
+	if status < 400 {
+		return body, nil
+	}
+	if status != 403 {
+		return nil, ErrParsingFailed
+	}
+	if v.EmbedOrigin != "" {
+		// the embed page of a password protected video has no config,
+		// it's unlocked on the video page
+		config, err := v.fetchEmbedConfig(ctx)
+		if !errors.Is(err, ErrParsingFailed) {
+			return config, err
+		}
+	}
+
+	// If the response is forbidden it tries another way to fetch link
+	page, status, err := v.get(ctx, v.Url)
+	if err != nil {
+		return nil, err
+	}
+	if isLocked(page) {
+		page, err = v.unlock(ctx, page)
+		if err != nil {
+			return nil, err
+		}
+	} else if status >= 400 {
+		return nil, ErrParsingFailed
+	}
+	pattern := fmt.Sprintf(
+		`"(%s.+?)"`,
+		strings.ReplaceAll(configUrl, "/", `\\/`),
+	)
+	rexp, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, ErrParsingFailed
+	}
+	configUrls := rexp.FindAll(page, 1)
+	if len(configUrls) == 0 {
+		return nil, ErrParsingFailed
+	}
+	configUrl = strings.Trim(strings.ReplaceAll(string(configUrls[0]), `\/`, "/"), `"`)
+	body, status, err = v.get(ctx, v.withHash(configUrl))
+	if err != nil {
+		return nil, err
+	}
+	if status >= 400 {
+		return nil, ErrParsingFailed
+	}
+	return body, nil
+}
+
+// fetchEmbedConfig returns the player config found on the embed page.
+func (v *Video) fetchEmbedConfig(ctx context.Context) ([]byte, error) {
+	embedUrl := fmt.Sprintf("%s/video/%v", v.Endpoints.withDefaults().Player, v.VideoId)
+	page, status, err := v.get(ctx, v.withHash(embedUrl))
+	if err != nil {
+		return nil, err
+	}
+	if status >= 400 {
+		return nil, ErrParsingFailed
+	}
+	loc := embedConfigPattern.FindIndex(page)
+	if loc == nil {
+		return nil, ErrParsingFailed
+	}
+	var config json.RawMessage
+	if err := json.NewDecoder(bytes.NewReader(page[loc[1]:])).Decode(&config); err != nil {
+		return nil, ErrParsingFailed
+	}
+	return config, nil
+}
+
+var embedConfigPattern = regexp.MustCompile(`playerConfig\s*=\s*`)
+
+// setEmbedHeaders sets Referer and Origin of the requests to the player
+// if the video has EmbedOrigin.
+func (v *Video) setEmbedHeaders(req *http.Request) {
+	if v.EmbedOrigin == "" {
+		return
+	}
+	player, err := url.Parse(v.Endpoints.withDefaults().Player)
+	if err != nil || req.URL.Host != player.Host {
+		return
+	}
+	req.Header.Set("Referer", v.EmbedOrigin)
+	if origin, err := url.Parse(v.EmbedOrigin); err == nil && origin.Host != "" {
+		req.Header.Set("Origin", origin.Scheme+"://"+origin.Host)
+	}
+}
+
+// get requests the URL with the headers of the video.
+func (v *Video) get(ctx context.Context, url string) ([]byte, int, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	req.Header = copyHeader(v.Header)
+	v.setEmbedHeaders(req)
+	resp, err := v.client().Do(req)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, 0, err
+	}
+	return body, resp.StatusCode, nil
+}
+
+// cached returns the value from the cache or fetches and caches it.
+// The value expires with the signed URLs in the key or in the value.
+func (v *Video) cached(key string, fetch func() ([]byte, error)) ([]byte, error) {
+	if v.Cache != nil {
+		if value, ok := v.Cache.Get(key); ok {
+			return value, nil
+		}
+	}
+
+	value, err := fetch()
+	if err != nil {
+		return nil, err
+	}
+	v.store(key, value)
+	return value, nil
+}
+
+// store caches the value if the video has a cache.
+func (v *Video) store(key string, value []byte) {
+	if v.Cache == nil {
+		return
+	}
+	expires := signedExpiry(value)
+	if keyExpires := signedExpiry([]byte(key)); expires.IsZero() ||
+		!keyExpires.IsZero() && keyExpires.Before(expires) {
+		expires = keyExpires
+	}
+	v.Cache.Set(key, value, expires)
+}
+
 // GetDashStreams returns DASH streams of the video.
 func (v *Video) GetDashStreams(dashUrl string) (*DashStreams, error) {
-	req, _ := http.NewRequest("GET", dashUrl, nil)
-	req.Header = v.Header
-	resp, err := v.HTTPClient.Do(req)
+	return v.GetDashStreamsContext(context.Background(), dashUrl)
+}
+
+// GetDashStreamsContext is like GetDashStreams but uses ctx for the request.
+func (v *Video) GetDashStreamsContext(ctx context.Context, dashUrl string) (*DashStreams, error) {
+	body, err := v.cached("dash:"+dashUrl, func() ([]byte, error) {
+		body, status, err := v.get(ctx, v.withHash(dashUrl))
+		if err == nil && status >= 400 {
+			err = ErrUnexpectedStatusCode(status)
+		}
+		return body, err
+	})
 	if err != nil {
 		return nil, err
 	}
-	defer resp.Body.Close()
 
 	var result DashStreams
-	err = json.NewDecoder(resp.Body).Decode(&result)
+	err = json.Unmarshal(body, &result)
 	if err != nil {
 		return nil, fmt.Errorf("couldn't decode dash JSON: %w", err)
 	}
@@ -152,10 +360,12 @@ func (v *Video) GetDashStreams(dashUrl string) (*DashStreams, error) {
 	for _, stream := range result.Video {
 		refurl, _ := url.Parse(stream.BaseURL)
 		stream.URL = baseurl.ResolveReference(refurl).String()
+		stream.httpClient = v.HTTPClient
 	}
 	for _, stream := range result.Audio {
 		refurl, _ := url.Parse(stream.BaseURL)
 		stream.URL = baseurl.ResolveReference(refurl).String()
+		stream.httpClient = v.HTTPClient
 	}
 
 	sort.Sort(result.Video)
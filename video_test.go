@@ -0,0 +1,400 @@
+package vimego
+
+import (
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestVideoMetadata(t *testing.T) {
+	fake := newFakeVimeo(t)
+
+	metadata, err := fake.video().Metadata()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if metadata.Title != "Crystal Castles - Kept" {
+		t.Errorf("metadata.Title == %q", metadata.Title)
+	}
+	if metadata.Duration != 243 || metadata.Likes != 1562 {
+		t.Errorf("metadata: %+v", metadata)
+	}
+}
+
+func TestVideoPlayerMetadata(t *testing.T) {
+	fake := newFakeVimeo(t)
+	fake.private = true
+
+	metadata, err := fake.video().Metadata()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := Metadata{
+		ID:                 206152466,
+		Title:              "Crystal Castles - Kept",
+		URL:                "https://vimeo.com/206152466",
+		ThumbnailSmall:     "https://i.vimeocdn.com/video/622183432-4f1e7b2c_100x75",
+		ThumbnailMedium:    "https://i.vimeocdn.com/video/622183432-4f1e7b2c_200x150",
+		ThumbnailLarge:     "https://i.vimeocdn.com/video/622183432-4f1e7b2c_640",
+		UserID:             11282009,
+		UserName:           "Crystal Castles",
+		UserURL:            "https://vimeo.com/crystalcastles",
+		UserPortraitSmall:  "https://i.vimeocdn.com/portrait/10582914_30x30",
+		UserPortraitMedium: "https://i.vimeocdn.com/portrait/10582914_75x75",
+		UserPortraitLarge:  "https://i.vimeocdn.com/portrait/10582914_100x100",
+		UserPortraitHuge:   "https://i.vimeocdn.com/portrait/10582914_300x300",
+		Duration:           243,
+		Width:              1920,
+		Height:             1080,
+		EmbedPrivacy:       "anywhere",
+	}
+	if *metadata != expected {
+		t.Errorf("metadata: %+v", metadata)
+	}
+	if n := fake.count("/video/206152466/config"); n != 1 {
+		t.Errorf("the config was requested %d times", n)
+	}
+}
+
+func TestVideoMetadataErrors(t *testing.T) {
+	for _, status := range []int{http.StatusTooManyRequests, http.StatusServiceUnavailable} {
+		fake := newFakeVimeo(t)
+		fake.metadataStatus = status
+
+		if _, err := fake.video().Metadata(); err != ErrUnexpectedStatusCode(status) {
+			t.Errorf("status %d: err == %v", status, err)
+		}
+		if n := fake.count("/video/206152466/config"); n != 0 {
+			t.Errorf("status %d: the config was requested %d times", status, n)
+		}
+	}
+}
+
+func TestVideoFormats(t *testing.T) {
+	fake := newFakeVimeo(t)
+
+	formats, err := fake.video().Formats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(formats.Progressive) != 2 {
+		t.Fatalf("len(formats.Progressive) == %d", len(formats.Progressive))
+	}
+	if best := formats.Progressive.Best(); best.Height != 1080 || best.Profile != 175 {
+		t.Errorf("formats.Progressive.Best(): %+v", best)
+	}
+	if !strings.HasPrefix(formats.Dash.Url(), "https://vod-adaptive.akamaized.net/") {
+		t.Errorf("formats.Dash.Url() == %q", formats.Dash.Url())
+	}
+	if !strings.HasSuffix(formats.Hls.Url(), "/master.m3u8") {
+		t.Errorf("formats.Hls.Url() == %q", formats.Hls.Url())
+	}
+	if n := fake.count("/206152466"); n != 0 {
+		t.Errorf("the video page was requested %d times", n)
+	}
+}
+
+func TestVideoFormatsForbidden(t *testing.T) {
+	fake := newFakeVimeo(t)
+	fake.forbidden = true
+
+	formats, err := fake.video().Formats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(formats.Progressive) != 2 {
+		t.Errorf("len(formats.Progressive) == %d", len(formats.Progressive))
+	}
+	if n := fake.count("/206152466"); n != 1 {
+		t.Errorf("the video page was requested %d times", n)
+	}
+	if n := fake.count("/video/206152466/config"); n != 2 {
+		t.Errorf("the config was requested %d times", n)
+	}
+}
+
+func TestVideoFormatsForbiddenFromURL(t *testing.T) {
+	fake := newFakeVimeo(t)
+	fake.forbidden = true
+
+	client := NewClient()
+	client.HTTPClient = fake.client()
+	for _, rawUrl := range []string{"vimeo.com/206152466", " https://vimeo.com/channels/staffpicks/206152466\n"} {
+		video, err := client.NewVideo(rawUrl)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if video.Url != "https://vimeo.com/206152466" {
+			t.Errorf("NewVideo(%q).Url == %q", rawUrl, video.Url)
+		}
+		if _, err := video.Formats(); err != nil {
+			t.Errorf("NewVideo(%q).Formats(): %v", rawUrl, err)
+		}
+	}
+}
+
+func TestVideoUnlisted(t *testing.T) {
+	fake := newFakeVimeo(t)
+	fake.hash = "3c5a1f9e0b"
+
+	client := NewClient()
+	client.HTTPClient = fake.client()
+	video, err := client.NewVideo("https://vimeo.com/206152466/3c5a1f9e0b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if video.Hash != "3c5a1f9e0b" {
+		t.Errorf("video.Hash == %q", video.Hash)
+	}
+
+	if _, err := video.Metadata(); err != nil {
+		t.Fatal(err)
+	}
+	formats, err := video.Formats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := video.GetDashStreams(formats.Dash.Url()); err != nil {
+		t.Fatal(err)
+	}
+
+	// without the hash the video isn't found
+	if _, err := fake.video().Formats(); err == nil {
+		t.Error("the config was served without the hash")
+	}
+}
+
+func TestVideoPassword(t *testing.T) {
+	fake := newFakeVimeo(t)
+	fake.password = "kept"
+
+	video := fake.video()
+	if _, err := video.Formats(); err != ErrPasswordRequired {
+		t.Errorf("err == %v", err)
+	}
+
+	video.Password = "wrong"
+	if _, err := video.Formats(); err != ErrWrongPassword {
+		t.Errorf("err == %v", err)
+	}
+
+	video.Password = "kept"
+	formats, err := video.Formats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(formats.Progressive) != 2 {
+		t.Errorf("len(formats.Progressive) == %d", len(formats.Progressive))
+	}
+	if n := fake.count("/206152466/password"); n != 2 {
+		t.Errorf("the password was sent %d times", n)
+	}
+}
+
+func TestVideoEmbedOrigin(t *testing.T) {
+	fake := newFakeVimeo(t)
+	fake.embedDomain = "https://example.com"
+
+	video := fake.video()
+	if _, err := video.Formats(); err == nil {
+		t.Error("the config was served without Referer")
+	}
+
+	video.EmbedOrigin = "https://example.com/videos/kept.html"
+	formats, err := video.Formats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(formats.Progressive) != 2 {
+		t.Errorf("len(formats.Progressive) == %d", len(formats.Progressive))
+	}
+
+	// the config is found on the embed page if the endpoint is forbidden
+	fake.forbidden = true
+	formats, err = video.Formats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(formats.Progressive) != 2 {
+		t.Errorf("len(formats.Progressive) == %d", len(formats.Progressive))
+	}
+	if n := fake.count("/video/206152466"); n != 1 {
+		t.Errorf("the embed page was requested %d times", n)
+	}
+}
+
+func TestVideoEmbedOriginPassword(t *testing.T) {
+	fake := newFakeVimeo(t)
+	fake.embedDomain = "https://example.com"
+	fake.password = "kept"
+
+	video := fake.video()
+	video.EmbedOrigin = "https://example.com/videos/kept.html"
+	if _, err := video.Formats(); err != ErrPasswordRequired {
+		t.Errorf("err == %v", err)
+	}
+
+	video.Password = "wrong"
+	if _, err := video.Formats(); err != ErrWrongPassword {
+		t.Errorf("err == %v", err)
+	}
+
+	video.Password = "kept"
+	formats, err := video.Formats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(formats.Progressive) != 2 {
+		t.Errorf("len(formats.Progressive) == %d", len(formats.Progressive))
+	}
+}
+
+func TestVideoTextTracks(t *testing.T) {
+	fake := newFakeVimeo(t)
+	video := fake.video()
+
+	tracks, err := video.TextTracks()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tracks) != 2 {
+		t.Fatalf("len(tracks) == %d", len(tracks))
+	}
+	if tracks[0].Lang != "en" || tracks[0].Kind != "captions" || tracks[0].AutoGenerated() {
+		t.Errorf("tracks[0]: %+v", tracks[0])
+	}
+	if !tracks[1].AutoGenerated() {
+		t.Errorf("tracks[1]: %+v", tracks[1])
+	}
+	if expected := "https://player.vimeo.com/texttrack/8817346.vtt?token=6357b1c0_0x5d2e8f1a"; tracks[0].URL != expected {
+		t.Errorf("tracks[0].URL == %q", tracks[0].URL)
+	}
+
+	cues, err := video.GetCues(tracks[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cues) != 3 {
+		t.Errorf("len(cues) == %d", len(cues))
+	}
+}
+
+func TestVideoFormatsNotFound(t *testing.T) {
+	fake := newFakeVimeo(t)
+
+	video := NewVideoFromId(1)
+	video.HTTPClient = fake.client()
+	_, err := video.Formats()
+	if err != ErrParsingFailed {
+		t.Errorf("err == %v", err)
+	}
+}
+
+func TestVideoDashStreams(t *testing.T) {
+	fake := newFakeVimeo(t)
+	video := fake.video()
+
+	formats, err := video.Formats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	streams, err := video.GetDashStreams(formats.Dash.Url())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(streams.Video) != 2 || len(streams.Audio) != 1 {
+		t.Fatalf("%d video and %d audio streams", len(streams.Video), len(streams.Audio))
+	}
+	if !strings.HasSuffix(streams.Audio.Best().URL, "/sep/audio/5d6e7f80/chop/") {
+		t.Errorf("audio URL == %q", streams.Audio.Best().URL)
+	}
+
+	reader, length, err := streams.Video.Best().ReaderWithOptions(&ReaderOptions{
+		HTTPClient:  video.HTTPClient,
+		Concurrency: 2,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reader.Close()
+
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "init 1080p8f03e7d2 segment 18f03e7d2 segment 2"
+	if string(data) != expected || length != int64(len(expected)) {
+		t.Errorf("read %q, length == %d", data, length)
+	}
+}
+
+func TestVideoStreamsUseVideoClient(t *testing.T) {
+	fake := newFakeVimeo(t)
+	video := fake.video()
+
+	formats, err := video.Formats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	progressive := formats.Progressive.Best()
+	reader, _, err := progressive.Reader(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	io.Copy(io.Discard, reader)
+	reader.Close()
+	progressiveUrl, _ := url.Parse(progressive.URL)
+	if n := fake.count(progressiveUrl.Path); n != 1 {
+		t.Errorf("the progressive file was requested %d times", n)
+	}
+
+	streams, err := video.GetDashStreams(formats.Dash.Url())
+	if err != nil {
+		t.Fatal(err)
+	}
+	reader, _, err = streams.Audio.Best().ReaderWithOptions(&ReaderOptions{Concurrency: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := io.ReadAll(reader)
+	reader.Close()
+	if err != nil || !strings.HasSuffix(string(data), "segment 2") {
+		t.Errorf("read %q, err == %v", data, err)
+	}
+}
+
+func TestVideoDashSegmentError(t *testing.T) {
+	fake := newFakeVimeo(t)
+	fake.brokenSegments["8f03e7d2/chop/segment-2.m4s"] = http.StatusInternalServerError
+	video := fake.video()
+
+	formats, err := video.Formats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	streams, err := video.GetDashStreams(formats.Dash.Url())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reader, _, err := streams.Video.Best().ReaderWithOptions(&ReaderOptions{
+		HTTPClient: video.HTTPClient,
+		RetryDelay: time.Millisecond,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reader.Close()
+
+	_, err = io.ReadAll(reader)
+	if err != ErrUnexpectedStatusCode(http.StatusInternalServerError) {
+		t.Errorf("err == %v", err)
+	}
+	segmentUrl, _ := url.Parse(streams.Video.Best().URL + "segment-2.m4s")
+	if n := fake.count(segmentUrl.Path); n != 1+defaultRetries {
+		t.Errorf("the segment was requested %d times", n)
+	}
+}
@@ -1,47 +1,43 @@
 // Package vimego: Search, download Vimeo videos and retrieve metadata.
 package vimego
 
-import (
-	"fmt"
-	"net/http"
-)
-
 const UserAgent = "Mozilla/5.0 (X11; Ubuntu; Linux x86_64; rv:84.0) Gecko/20100101 Firefox/84.0"
 
 // NewVideo creates a new Video from URL.
 func NewVideo(url string) (*Video, error) {
-	videoId := validateUrl(url)
-	if videoId == 0 {
-		return nil, ErrInvalidUrl
-	}
-
-	return &Video{
-		Url:        url,
-		VideoId:    videoId,
-		HTTPClient: &http.Client{},
-		Header:     map[string][]string{"User-Agent": {UserAgent}},
-	}, nil
+	return NewClient().NewVideo(url)
 }
 
 // NewVideo creates a new Video from video ID.
 func NewVideoFromId(videoId int) *Video {
-	return &Video{
-		Url:        fmt.Sprintf("https://vimeo.com/%v", videoId),
-		VideoId:    videoId,
-		HTTPClient: &http.Client{},
-		Header:     map[string][]string{"User-Agent": {UserAgent}},
-	}
+	return NewClient().NewVideoFromId(videoId)
 }
 
 // NewSearchClient creates a new SearchClient with default parameters.
 func NewSearchClient() *SearchClient {
-	return &SearchClient{
-		PerPage:    18,
-		Filter:     VideoFilter,
-		Order:      RelevanceOrder,
-		Direction:  DescDirection,
-		Category:   AnyCategory,
-		HTTPClient: &http.Client{},
-		Header:     map[string][]string{"User-Agent": {UserAgent}},
-	}
+	return NewClient().NewSearchClient()
+}
+
+// NewShowcase creates a new Showcase from URL, such as
+// https://vimeo.com/showcase/8156389 or https://vimeo.com/album/8156389.
+func NewShowcase(url string) (*Showcase, error) {
+	return NewClient().NewShowcase(url)
+}
+
+// NewUser creates a new User from the profile URL, such as
+// https://vimeo.com/crystalcastles, or the name in it.
+func NewUser(url string) (*User, error) {
+	return NewClient().NewUser(url)
+}
+
+// NewChannel creates a new Channel from the channel URL, such as
+// https://vimeo.com/channels/staffpicks, or the name in it.
+func NewChannel(url string) (*Channel, error) {
+	return NewClient().NewChannel(url)
+}
+
+// NewGroup creates a new Group from the group URL, such as
+// https://vimeo.com/groups/musicvideos, or the name in it.
+func NewGroup(url string) (*Group, error) {
+	return NewClient().NewGroup(url)
 }
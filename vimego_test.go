@@ -1,3 +1,8 @@
+//go:build live
+// +build live
+
+// The tests requesting vimeo.com, run with -tags live.
+
 package vimego
 
 import "testing"
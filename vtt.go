@@ -0,0 +1,184 @@
+package vimego
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Cue is a caption shown from Start to End.
+type Cue struct {
+	ID    string
+	Start time.Duration
+	End   time.Duration
+	// Text may contain the WebVTT tags, such as <i> or <v Speaker>.
+	Text string
+}
+
+type Cues []*Cue
+
+var (
+	vttTagPattern = regexp.MustCompile(`<[^>]*>`)
+	srtTagPattern = regexp.MustCompile(`^</?[biu]>$`)
+)
+
+// ParseVTT parses a WebVTT file. The notes, styles and regions are skipped.
+func ParseVTT(data []byte) (Cues, error) {
+	data = bytes.TrimPrefix(data, []byte("\ufeff"))
+	lines := strings.Split(strings.ReplaceAll(string(data), "\r\n", "\n"), "\n")
+	if header := lines[0]; header != "WEBVTT" &&
+		!strings.HasPrefix(header, "WEBVTT ") && !strings.HasPrefix(header, "WEBVTT\t") {
+		return nil, fmt.Errorf("%w: no WEBVTT header", ErrInvalidVTT)
+	}
+
+	var cues Cues
+	var block []string
+	flush := func() error {
+		if block == nil {
+			return nil
+		}
+		cue, err := parseCue(block)
+		if cue != nil {
+			cues = append(cues, cue)
+		}
+		block = nil
+		return err
+	}
+	for _, line := range lines[1:] {
+		if line != "" {
+			block = append(block, line)
+			continue
+		}
+		if err := flush(); err != nil {
+			return nil, err
+		}
+	}
+	if err := flush(); err != nil {
+		return nil, err
+	}
+	return cues, nil
+}
+
+// parseCue returns nil if the block isn't a cue.
+func parseCue(block []string) (*Cue, error) {
+	cue := &Cue{}
+	switch {
+	case strings.Contains(block[0], "-->"):
+	case len(block) > 1 && strings.Contains(block[1], "-->"):
+		cue.ID = block[0]
+		block = block[1:]
+	default:
+		// a note, a style, a region or the rest of the header
+		return nil, nil
+	}
+
+	timing := strings.SplitN(block[0], "-->", 2)
+	end := strings.Fields(timing[1])
+	if len(end) == 0 {
+		return nil, fmt.Errorf("%w: invalid timing %q", ErrInvalidVTT, block[0])
+	}
+	var err error
+	if cue.Start, err = parseVTTTimestamp(strings.TrimSpace(timing[0])); err != nil {
+		return nil, err
+	}
+	if cue.End, err = parseVTTTimestamp(end[0]); err != nil {
+		return nil, err
+	}
+	cue.Text = strings.Join(block[1:], "\n")
+	return cue, nil
+}
+
+// parseVTTTimestamp parses hh:mm:ss.ttt or mm:ss.ttt.
+func parseVTTTimestamp(s string) (time.Duration, error) {
+	invalid := fmt.Errorf("%w: invalid timestamp %q", ErrInvalidVTT, s)
+	parts := strings.Split(s, ":")
+	if len(parts) == 2 {
+		parts = append([]string{"0"}, parts...)
+	}
+	if len(parts) != 3 {
+		return 0, invalid
+	}
+	seconds := strings.SplitN(parts[2], ".", 2)
+	if len(seconds) != 2 || len(seconds[1]) != 3 {
+		return 0, invalid
+	}
+
+	var values [4]int
+	for i, value := range []string{parts[0], parts[1], seconds[0], seconds[1]} {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 {
+			return 0, invalid
+		}
+		values[i] = n
+	}
+	return time.Duration(values[0])*time.Hour +
+		time.Duration(values[1])*time.Minute +
+		time.Duration(values[2])*time.Second +
+		time.Duration(values[3])*time.Millisecond, nil
+}
+
+var vttEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+
+// VTT returns the cues as a WebVTT file.
+func (c Cues) VTT() []byte {
+	var buf bytes.Buffer
+	buf.WriteString("WEBVTT\n")
+	for _, cue := range c {
+		buf.WriteString("\n")
+		if cue.ID != "" {
+			buf.WriteString(cue.ID + "\n")
+		}
+		fmt.Fprintf(&buf, "%s --> %s\n%s\n",
+			vttTimestamp(cue.Start), vttTimestamp(cue.End), cue.Text)
+	}
+	return buf.Bytes()
+}
+
+func vttTimestamp(d time.Duration) string {
+	return fmt.Sprintf("%02d:%02d:%02d.%03d",
+		d/time.Hour, d/time.Minute%60, d/time.Second%60, d/time.Millisecond%1000)
+}
+
+// SRT returns the cues in the SubRip format. Only the <b>, <i> and <u>
+// tags are kept.
+func (c Cues) SRT() []byte {
+	var buf bytes.Buffer
+	for i, cue := range c {
+		text := vttTagPattern.ReplaceAllStringFunc(cue.Text, func(tag string) string {
+			if srtTagPattern.MatchString(tag) {
+				return tag
+			}
+			return ""
+		})
+		fmt.Fprintf(&buf, "%d\n%s --> %s\n%s\n\n",
+			i+1, srtTimestamp(cue.Start), srtTimestamp(cue.End), html.UnescapeString(text))
+	}
+	return buf.Bytes()
+}
+
+func srtTimestamp(d time.Duration) string {
+	return fmt.Sprintf("%02d:%02d:%02d,%03d",
+		d/time.Hour, d/time.Minute%60, d/time.Second%60, d/time.Millisecond%1000)
+}
+
+// Text returns the text of the cues without tags, one line per line
+// of the cues. The repeated lines, common in the auto-generated
+// captions, are written once.
+func (c Cues) Text() string {
+	var lines []string
+	for _, cue := range c {
+		text := html.UnescapeString(vttTagPattern.ReplaceAllString(cue.Text, ""))
+		for _, line := range strings.Split(text, "\n") {
+			line = strings.TrimSpace(line)
+			if line == "" || len(lines) != 0 && lines[len(lines)-1] == line {
+				continue
+			}
+			lines = append(lines, line)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
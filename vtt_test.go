@@ -0,0 +1,66 @@
+package vimego
+
+import (
+	"errors"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestParseVTT(t *testing.T) {
+	data, err := os.ReadFile("testdata/captions.vtt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cues, err := ParseVTT(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []Cue{
+		{"1", 12 * time.Second, 15500 * time.Millisecond, "<v Alice>Hold my hand</v>"},
+		{"2", 15500 * time.Millisecond, 19250 * time.Millisecond, "<i>Hold my hand</i>\nand we'll run &amp; hide"},
+		{"", 62 * time.Second, 64120 * time.Millisecond, "It's dark &lt;now&gt;"},
+	}
+	if len(cues) != len(expected) {
+		t.Fatalf("len(cues) == %d", len(cues))
+	}
+	for i, cue := range cues {
+		if *cue != expected[i] {
+			t.Errorf("cues[%d]: %+v", i, cue)
+		}
+	}
+
+	for _, invalid := range []string{
+		"1\n00:00.000 --> 00:01.000\ntext",
+		"WEBVTT\n\n00:00 --> 00:01.000\ntext",
+		"WEBVTT\n\n00:00.000 -->\ntext",
+	} {
+		if _, err := ParseVTT([]byte(invalid)); !errors.Is(err, ErrInvalidVTT) {
+			t.Errorf("ParseVTT(%q): err == %v", invalid, err)
+		}
+	}
+}
+
+func TestCuesSRT(t *testing.T) {
+	cues := Cues{
+		{Start: 12 * time.Second, End: 15500 * time.Millisecond, Text: "<v Alice>Hold my hand</v>"},
+		{Start: time.Hour + 2*time.Second, End: time.Hour + 4120*time.Millisecond, Text: "<i>dark</i> &amp; <c.loud>cold</c>"},
+	}
+	expected := "1\n00:00:12,000 --> 00:00:15,500\nHold my hand\n\n" +
+		"2\n01:00:02,000 --> 01:00:04,120\n<i>dark</i> & cold\n\n"
+	if srt := string(cues.SRT()); srt != expected {
+		t.Errorf("SRT() == %q", srt)
+	}
+}
+
+func TestCuesText(t *testing.T) {
+	cues := Cues{
+		{Text: "<v Alice>Hold my hand</v>"},
+		{Text: "<i>Hold my hand</i>\nand we'll run &amp; hide"},
+		{Text: " \n"},
+	}
+	expected := "Hold my hand\nand we'll run & hide"
+	if text := cues.Text(); text != expected {
+		t.Errorf("Text() == %q", text)
+	}
+}